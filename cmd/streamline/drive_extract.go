@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	streamline_core "Streamline/cmd/streamline_core"
+	downloader "Streamline/internal/downloader"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// runExtractToDrive handles -toDrive: entries are uploaded under folderID
+// as they are decompressed, and nothing is written locally.
+func runExtractToDrive(ctx context.Context, svc *drive.Service, arc streamline_core.Archive, folderID string, opts streamline_core.ExtractOptions, retry downloader.RetryPolicy) {
+    opts.OnEntry = func(e streamline_core.Entry, name string, err error) {
+        if err != nil {
+            log.Printf("[ERROR] Failed to upload %s: %v", e.Name, err)
+        } else {
+            log.Printf("Uploaded: %s", name)
+        }
+    }
+    summary, err := downloader.ExtractToDrive(ctx, svc, arc, folderID, opts, retry)
+    if summary != nil {
+        log.Printf("Extraction to Drive complete. Total: %d, Uploaded: %d, Skipped: %d, Errors: %d",
+            summary.Total, summary.Extracted, summary.Skipped, len(summary.Errors))
+    }
+    if err != nil {
+        log.Fatalf("extract to Drive: %v", err)
+    }
+    if len(summary.Errors) > 0 {
+        os.Exit(1)
+    }
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+// listFormats are the values accepted by -listFormat.
+var listFormats = []string{"table", "json", "csv", "tree"}
+
+// listEntry is the JSON and CSV shape of one archive entry.
+type listEntry struct {
+    Name           string `json:"name"`
+    Size           int64  `json:"size"`
+    CompressedSize int64  `json:"compressedSize"`
+    Method         string `json:"method,omitempty"`
+    CRC32          string `json:"crc32,omitempty"`
+    Modified       string `json:"modified,omitempty"`
+    Mode           string `json:"mode"`
+    IsDir          bool   `json:"isDir"`
+    Encrypted      bool   `json:"encrypted"`
+    Comment        string `json:"comment,omitempty"`
+}
+
+func newListEntry(e streamline_core.Entry) listEntry {
+    le := listEntry{
+        Name:           e.Name,
+        Size:           e.Size,
+        CompressedSize: e.CompressedSize,
+        Method:         e.Method,
+        Mode:           e.Mode.String(),
+        IsDir:          e.IsDir,
+        Encrypted:      e.Encrypted,
+        Comment:        e.Comment,
+    }
+    if e.HasCRC && !e.IsDir {
+        le.CRC32 = fmt.Sprintf("%08x", e.CRC32)
+    }
+    if !e.Modified.IsZero() {
+        le.Modified = e.Modified.Format(time.RFC3339)
+    }
+    return le
+}
+
+// printEntries prints the entries filter selects to stdout for -list.
+func printEntries(all []streamline_core.Entry, filter *streamline_core.Filter, format string) {
+    var entries []streamline_core.Entry
+    for _, e := range all {
+        if filter.Match(e) {
+            entries = append(entries, e)
+        }
+    }
+    if err := printListing(os.Stdout, format, entries); err != nil {
+        log.Fatalf("list archive: %v", err)
+    }
+    if n := countEncrypted(entries); n > 0 {
+        log.Printf("%d of %d entries are encrypted; supply -password or STREAMLINE_ZIP_PASSWORD to extract them", n, len(entries))
+    }
+}
+
+// printListing renders entries to w in one of listFormats.
+func printListing(w io.Writer, format string, entries []streamline_core.Entry) error {
+    switch format {
+    case "table":
+        return printTable(w, entries)
+    case "json":
+        list := make([]listEntry, 0, len(entries))
+        for _, e := range entries {
+            list = append(list, newListEntry(e))
+        }
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        return enc.Encode(list)
+    case "csv":
+        return printCSV(w, entries)
+    case "tree":
+        printTree(w, entries)
+        return nil
+    }
+    return fmt.Errorf("unknown list format %q (want one of %s)", format, strings.Join(listFormats, ", "))
+}
+
+func printTable(w io.Writer, entries []streamline_core.Entry) error {
+    tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "SIZE\tPACKED\tMETHOD\tCRC32\tMODIFIED\tMODE\tNAME")
+    var size, packed int64
+    for _, e := range entries {
+        le := newListEntry(e)
+        modified := ""
+        if !e.Modified.IsZero() {
+            modified = e.Modified.Local().Format("2006-01-02 15:04")
+        }
+        name := e.Name
+        if e.Encrypted {
+            name += " [encrypted]"
+        }
+        if e.Comment != "" {
+            name += "  # " + e.Comment
+        }
+        fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t%s\t%s\t%s\n",
+            e.Size, e.CompressedSize, le.Method, le.CRC32, modified, le.Mode, name)
+        size += max(e.Size, 0)
+        packed += e.CompressedSize
+    }
+    fmt.Fprintf(tw, "%d\t%d\t\t\t\t\t%d entries\n", size, packed, len(entries))
+    return tw.Flush()
+}
+
+func printCSV(w io.Writer, entries []streamline_core.Entry) error {
+    cw := csv.NewWriter(w)
+    cw.Write([]string{"name", "size", "compressed_size", "method", "crc32", "modified", "mode", "is_dir", "encrypted", "comment"})
+    for _, e := range entries {
+        le := newListEntry(e)
+        cw.Write([]string{
+            le.Name, strconv.FormatInt(le.Size, 10), strconv.FormatInt(le.CompressedSize, 10),
+            le.Method, le.CRC32, le.Modified, le.Mode,
+            strconv.FormatBool(le.IsDir), strconv.FormatBool(le.Encrypted), le.Comment,
+        })
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// treeNode is a directory level of the tree view.
+type treeNode struct {
+    children map[string]*treeNode
+    entry    *streamline_core.Entry // nil for directories implied by paths
+}
+
+func printTree(w io.Writer, entries []streamline_core.Entry) {
+    root := &treeNode{children: map[string]*treeNode{}}
+    for i := range entries {
+        node := root
+        for _, part := range strings.Split(strings.Trim(entries[i].Name, "/"), "/") {
+            child, ok := node.children[part]
+            if !ok {
+                child = &treeNode{children: map[string]*treeNode{}}
+                node.children[part] = child
+            }
+            node = child
+        }
+        node.entry = &entries[i]
+    }
+    fmt.Fprintln(w, ".")
+    root.print(w, "")
+}
+
+func (n *treeNode) print(w io.Writer, indent string) {
+    names := make([]string, 0, len(n.children))
+    for name := range n.children {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for i, name := range names {
+        child := n.children[name]
+        branch, next := "├── ", "│   "
+        if i == len(names)-1 {
+            branch, next = "└── ", "    "
+        }
+        label := name
+        if len(child.children) > 0 || (child.entry != nil && child.entry.IsDir) {
+            label += "/"
+        } else if child.entry != nil {
+            label += fmt.Sprintf(" (%d bytes)", child.entry.Size)
+        }
+        if child.entry != nil && child.entry.Encrypted {
+            label += " [encrypted]"
+        }
+        fmt.Fprintln(w, indent+branch+label)
+        child.print(w, indent+next)
+    }
+}
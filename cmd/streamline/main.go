@@ -1,25 +1,31 @@
 package main
 
 import (
-	"archive/zip"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	streamline_core "Streamline/cmd/streamline_core"
 	config "Streamline/internal"
+	"Streamline/internal/app"
 	"Streamline/internal/auth"
 	downloader "Streamline/internal/downloader"
 	util "Streamline/internal/util"
 
 	"github.com/joho/godotenv"
+	"golang.org/x/term"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/option"
 )
@@ -32,6 +38,7 @@ const Version = "v0.1.0"
 type ExtractionError struct {
     File   string `json:"file"`
     Reason string `json:"reason"`
+    Code   string `json:"code,omitempty"`
 }
 
 func init() {
@@ -67,19 +74,69 @@ func main() {
     }
 
 
-    fileID := flag.String("fileId", "", "Google Drive File ID of the ZIP")
-    outDir := flag.String("out", "", "Output directory for extraction")
+    fileID := flag.String("fileId", "", "Google Drive File ID of the archive (zip, tar, tar.gz/bz2/xz/zst, 7z, rar); for split .z01/.z02/.zip sets, every part's ID in order, comma-separated")
+    outDir := flag.String("out", "", "Output directory for extraction, or the archive file to write with -pack")
     chunkMB := flag.Int("chunkMB", 16, "Chunk size in MB for caching (default 16)")
     skipErrors := flag.Bool("skip-errors", false, "Skip files that fail to extract instead of aborting")
-    listMode := flag.Bool("list", false, "List archive contents without extracting")
-    include := flag.String("include", "", "Glob pattern of files to include (e.g. *.pdf)")
-    exclude := flag.String("exclude", "", "Glob pattern of files to exclude (e.g. *.exe)")
+    listMode := flag.Bool("list", false, "List archive contents to stdout without extracting")
+    listFormat := flag.String("listFormat", "table", "Output of -list: "+strings.Join(listFormats, ", "))
+    verifyMode := flag.Bool("verify", false, "Check files already under -out against the archive's sizes and CRC32s without extracting")
+    var includes, excludes patternList
+    flag.Var(&includes, "include", "Glob of entries to include, e.g. *.pdf or docs/**/*.md (repeatable)")
+    flag.Var(&excludes, "exclude", "Glob of entries to exclude, e.g. *.exe or node_modules (repeatable; !glob re-includes)")
+    minSize := flag.String("minSize", "", "Skip files smaller than this (e.g. 10KB, 1.5MiB)")
+    maxSize := flag.String("maxSize", "", "Skip files larger than this (e.g. 2GB)")
+    after := flag.String("after", "", "Skip files modified before this date (YYYY-MM-DD or RFC 3339)")
+    before := flag.String("before", "", "Skip files modified on or after this date (YYYY-MM-DD or RFC 3339)")
+    stripComponents := flag.Int("stripComponents", 0, "Remove this many leading directories from entry paths")
+    var rewrites patternList
+    flag.Var(&rewrites, "rewrite", "Replace a leading directory of entry paths, as from=to (e.g. src=lib; repeatable)")
+    flatten := flag.Bool("flatten", false, "Extract all files directly into -out; name collisions get _1, _2 suffixes")
+    filterFile := flag.String("filterFile", cfg.FilterFile, "File of filter rules (default $STREAMLINE_FILTER_FILE, else ./"+streamline_core.DefaultFilterFile+" if present)")
     boost := flag.Bool("boost", false, "Enable parallel extraction of files")
     workers := flag.Int("workers", 4, "Number of parallel workers for boost mode")
     showVersion := flag.Bool("version", false, "Print version and exit")
     urlFlag := flag.String("url", "", "Download a file from URL and upload to Drive")
     driveFolder := flag.String("driveFolder", "root", "Target Drive folder ID for uploads")
     torrentFlag := flag.String("torrent", "", "Download a file from a torrent magnet link and upload to Drive")
+    segments := flag.Int("segments", downloader.DefaultURLSegments, "Parallel range requests for -url when the server supports them (1 reads sequentially)")
+    segmentMB := flag.Int("segmentMB", downloader.DefaultSegmentSize>>20, "Size in MB of each -url range request")
+    var headers, cookies patternList
+    flag.Var(&headers, "header", "Extra request header for -url, as \"Name: value\" (repeatable)")
+    flag.Var(&cookies, "cookie", "Cookie to send with -url, as name=value (repeatable)")
+    basicAuth := flag.String("user", "", "Basic auth for -url as user:password (password prompted for when omitted)")
+    bearer := flag.String("bearer", cfg.BearerToken, "Bearer token for -url (default $STREAMLINE_BEARER_TOKEN)")
+    proxyURL := flag.String("proxy", "", "Proxy for -url: http://, https:// or socks5:// URL (default $HTTPS_PROXY/$HTTP_PROXY)")
+    caBundle := flag.String("caBundle", "", "PEM file of extra CA certificates to trust for -url")
+    userAgent := flag.String("userAgent", "", "User-Agent header for -url")
+    netrcFile := flag.String("netrc", cfg.NetrcFile, "netrc file with per-host credentials for -url and its redirects (default $NETRC)")
+    checksum := flag.String("checksum", "", "Expected digest of the -url/-torrent download as sha256:<hex>; a mismatch deletes the upload")
+    checksumSidecar := flag.Bool("checksumSidecar", false, "Without -checksum, verify -url against a <URL>.sha256 or SHA256SUMS file next to it, if there is one")
+    uploadChunkMB := flag.Int("uploadChunkMB", downloader.DefaultUploadChunkSize>>20, "Chunk size in MB for resumable uploads to Drive (rounded up to 256 KB)")
+    uploadDir := flag.String("uploadDir", cfg.UploadDir, "Directory that remembers unfinished -url/-torrent uploads so a rerun resumes them (default $STREAMLINE_UPLOAD_DIR, else the user cache dir; \"off\" disables)")
+    cacheMB := flag.Int("cacheMB", 256, "Maximum MB of downloaded chunks kept in memory")
+    cacheDir := flag.String("cacheDir", cfg.CacheDir, "Directory for a persistent chunk cache shared across runs (empty disables)")
+    readAhead := flag.Int("readahead", downloader.DefaultReadAhead, "Chunks to prefetch during sequential reads (negative disables)")
+    parallel := flag.Int("parallel", downloader.DefaultParallelism, "Maximum concurrent prefetch requests")
+    retries := flag.Int("retries", downloader.DefaultRetryPolicy.MaxAttempts, "Attempts per Drive request before giving up on transient errors")
+    retryBase := flag.Duration("retryBase", downloader.DefaultRetryPolicy.BaseDelay, "Initial retry backoff (grows exponentially with jitter)")
+    retryMax := flag.Duration("retryMax", downloader.DefaultRetryPolicy.MaxDelay, "Maximum retry backoff")
+    resume := flag.Bool("resume", true, "Skip entries recorded as complete in the output directory's checkpoint")
+    maxTotalMB := flag.Int64("maxTotalMB", 0, "Abort once this many MB have been extracted in total (0 = unlimited)")
+    maxEntryMB := flag.Int64("maxEntryMB", 0, "Fail any entry that decompresses to more than this many MB (0 = unlimited)")
+    maxEntries := flag.Int("maxEntries", streamline_core.DefaultLimits.MaxEntries, "Abort after extracting this many files (0 = unlimited)")
+    maxRatio := flag.Float64("maxRatio", streamline_core.DefaultLimits.MaxRatio, "Fail entries that expand more than this many times their compressed size (0 = unlimited)")
+    maxDepth := flag.Int("maxDepth", streamline_core.DefaultLimits.MaxDepth, "Fail entries nested deeper than this many directories (0 = unlimited)")
+    password := flag.String("password", "", "Password for encrypted archives (default $STREAMLINE_ZIP_PASSWORD; prompted for when needed)")
+    preserveModes := flag.Bool("preserveModes", false, "Apply archived permission bits to extracted files and directories")
+    preserveTimes := flag.Bool("preserveTimes", false, "Apply archived modification times to extracted files and directories")
+    onConflict := flag.String("onConflict", string(streamline_core.ConflictOverwrite), "What to do when an output file already exists: overwrite, skip, rename, newer or fail")
+    preserveSymlinks := flag.Bool("preserveSymlinks", false, "Recreate symlinks (only those pointing inside -out) instead of writing them as files")
+    packSource := flag.String("pack", "", "Build an archive from a local directory, or from a Drive folder given as drive:<folderID>; written to -out if set, else uploaded to -driveFolder")
+    packFormat := flag.String("packFormat", "", "Archive format for -pack: zip or tar.gz (default from the -out or -packName extension, else zip)")
+    packName := flag.String("packName", "", "File name for the uploaded -pack archive (default: the source's name)")
+    toDrive := flag.Bool("toDrive", false, "Extract into -driveFolder on Drive instead of -out, without writing anything locally")
+    level := flag.Int("level", streamline_core.DefaultCompression, "Compression level for -pack: 0 (store) to 9 (best), -1 for the default")
 
     flag.BoolVar(&verbose, "verbose", false, "Enable detailed debug logging")
     flag.Parse()
@@ -89,14 +146,71 @@ func main() {
         os.Exit(0)
     }
 
-    // Downloader orchestration
-    d := downloader.NewDownloaderFromFlags(*urlFlag, *torrentFlag, *fileID, *outDir)
-    if d == nil {
-        flag.Usage()
-        os.Exit(1)
+    conflictPolicy, err := streamline_core.ParseConflictPolicy(*onConflict)
+    if err != nil {
+        log.Fatalf("-onConflict: %v", err)
+    }
+    if *listMode && !slices.Contains(listFormats, *listFormat) {
+        log.Fatalf("-listFormat: want one of %s", strings.Join(listFormats, ", "))
+    }
+    paths := streamline_core.PathMapping{StripComponents: *stripComponents, Flatten: *flatten}
+    for _, r := range rewrites {
+        rw, err := streamline_core.ParsePathRewrite(r)
+        if err != nil {
+            log.Fatalf("-rewrite: %v", err)
+        }
+        paths.Rewrites = append(paths.Rewrites, rw)
+    }
+    filter, err := buildFilter(*filterFile, includes, excludes, *minSize, *maxSize, *after, *before)
+    if err != nil {
+        log.Fatalf("filter: %v", err)
+    }
+
+    retry := downloader.RetryPolicy{
+        MaxAttempts: *retries,
+        BaseDelay:   *retryBase,
+        MaxDelay:    *retryMax,
+    }
+
+    if *packSource != "" {
+        packer := &downloader.Packer{
+            Level:   *level,
+            OutPath: *outDir,
+            Name:    *packName,
+            Retry:   retry,
+            Options: streamline_core.ExtractOptions{
+                Select:          filter.Match,
+                Paths:           paths,
+                ContinueOnError: *skipErrors,
+            },
+        }
+        if *packFormat != "" {
+            if packer.Format, err = streamline_core.ParsePackFormat(*packFormat); err != nil {
+                log.Fatalf("-packFormat: %v", err)
+            }
+        }
+        if id, ok := strings.CutPrefix(*packSource, "drive:"); ok {
+            packer.FolderID = id
+        } else {
+            packer.Dir = *packSource
+        }
+        ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+        defer stop()
+        runPack(ctx, packer, *driveFolder)
+        return
+    }
+
+    // -url and -torrent transfer into Drive; otherwise -fileId is extracted.
+    if *urlFlag == "" && *torrentFlag == "" {
+        if err := checkExtractFlags(*fileID, *outDir, *toDrive, *verifyMode); err != nil {
+            flag.Usage()
+            log.Fatalf("%v", err)
+        }
     }
 
-    ctx := context.Background()
+    // Ctrl-C cancels in-flight range requests and the entry being written.
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
     httpClient, err := auth.GetClient(ctx)
     if err != nil {
         log.Fatalf("auth client: %v", err)
@@ -106,133 +220,438 @@ func main() {
         log.Fatalf("drive service: %v", err)
     }
 
-    uploadedID, err := d.DownloadAndUpload(ctx, svc, *driveFolder)
-    if err != nil {
-        log.Fatalf("Download/Upload failed: %v", err)
+    if *urlFlag != "" || *torrentFlag != "" {
+        sessionDir := *uploadDir
+        if sessionDir == "" {
+            if dir, err := os.UserCacheDir(); err == nil {
+                sessionDir = filepath.Join(dir, "streamline", "uploads")
+            }
+        } else if sessionDir == "off" {
+            sessionDir = ""
+        }
+        httpOpts, err := buildHTTPOptions(headers, cookies, *basicAuth)
+        if err != nil {
+            log.Fatalf("%v", err)
+        }
+        httpOpts.BearerToken, httpOpts.UserAgent = *bearer, *userAgent
+        httpOpts.Proxy, httpOpts.CABundle, httpOpts.NetrcFile = *proxyURL, *caBundle, *netrcFile
+        var want downloader.Checksum
+        if *checksum != "" {
+            if want, err = downloader.ParseChecksum(*checksum); err != nil {
+                log.Fatalf("-checksum: %v", err)
+            }
+        }
+        uploadedID, err := app.RunDownload(ctx, svc, app.DownloadParams{
+            URL:             *urlFlag,
+            Torrent:         *torrentFlag,
+            DriveFolder:     *driveFolder,
+            Segments:        *segments,
+            SegmentSize:     int64(*segmentMB) << 20,
+            HTTP:            httpOpts,
+            Retry:           retry,
+            Checksum:        want,
+            ChecksumSidecar: *checksumSidecar,
+            Upload: downloader.UploadOptions{
+                Client:     httpClient,
+                ChunkSize:  int64(*uploadChunkMB) << 20,
+                SessionDir: sessionDir,
+                Retry:      retry,
+                OnProgress: func(sent, total int64) {
+                    log.Printf("Uploaded %d of %d bytes", sent, total)
+                    util.PrintTransfer(sent, total)
+                },
+            },
+        })
+        if err != nil {
+            log.Fatalf("Download/Upload failed: %v", err)
+        }
+        log.Printf("✅ Operation complete. File ID: %s", uploadedID)
+        return
     }
-    log.Printf("✅ Operation complete. File ID: %s", uploadedID)
 
     // Extraction
-    if *fileID == "" || *outDir == "" {
-        log.Fatalf("Usage: %s -fileId <ID> -out <path> [-chunkMB N]", os.Args[0])
-    }
-    if err := os.MkdirAll(*outDir, 0o755); err != nil {
-        log.Fatalf("create output dir: %v", err)
+    if !*verifyMode && !*toDrive {
+        if err := os.MkdirAll(*outDir, 0o755); err != nil {
+            log.Fatalf("create output dir: %v", err)
+        }
     }
 
-    meta, err := svc.Files.Get(*fileID).Fields("name,size").Do()
+    cache, err := downloader.NewChunkCache(int64(*cacheMB)*1024*1024, *cacheDir)
     if err != nil {
-        log.Fatalf("get file metadata: %v", err)
+        log.Fatalf("chunk cache: %v", err)
     }
-    if meta.Size == 0 {
-        log.Fatalf("file size is 0 or unknown; ensure it's a ZIP and accessible")
+    readerAt, metas, err := downloader.OpenDriveParts(ctx, svc, splitIDs(*fileID), downloader.DriveReaderOptions{
+        Context:     ctx,
+        ChunkSize:   int64(*chunkMB) * 1024 * 1024,
+        Cache:       cache,
+        ReadAhead:   *readAhead,
+        Parallelism: *parallel,
+        Retry:       retry,
+    })
+    if err != nil {
+        log.Fatalf("open archive: %v", err)
+    }
+    for _, meta := range metas {
+        log.Printf("Extracting: %s (%d bytes)", meta.Name, meta.Size)
+    }
+
+    // A single ZIP lists from its central directory alone; chunked reads
+    // would pull -chunkMB at a time to get there.
+    if *listMode && len(metas) == 1 {
+        listing, err := downloader.ListDriveZip(ctx, svc, splitIDs(*fileID)[0], metas[0].Size, retry)
+        if err == nil {
+            printEntries(listing.Entries, filter, *listFormat)
+            log.Printf("Listed from the central directory: %d requests, %d bytes transferred", listing.Requests, listing.BytesTransferred)
+            fmt.Fprintf(os.Stderr, "%d entries; %d bytes transferred in %d requests\n", len(listing.Entries), listing.BytesTransferred, listing.Requests)
+            return
+        }
+        if !errors.Is(err, streamline_core.ErrUnsupportedFormat) {
+            log.Fatalf("list archive: %v", err)
+        }
+        log.Printf("Not a ZIP (%d bytes transferred probing); listing via the archive reader", listing.BytesTransferred)
     }
-    log.Printf("Extracting: %s (%d bytes)", meta.Name, meta.Size)
 
-    readerAt := downloader.NewDriveReaderAt(svc, *fileID, meta.Size, int64(*chunkMB)*1024*1024)
-    zr, err := zip.NewReader(readerAt, meta.Size)
+    archivePassword := *password
+    if archivePassword == "" {
+        archivePassword = cfg.ZipPassword
+    }
+    arc, err := streamline_core.NewArchiveWithOptions(readerAt, readerAt.Size(), streamline_core.ArchiveOptions{Password: archivePassword})
+    if errors.Is(err, streamline_core.ErrPasswordRequired) && archivePassword == "" {
+        // Archives with encrypted headers cannot even be listed without it.
+        if archivePassword, err = promptPassword("Archive password: "); err != nil {
+            log.Fatalf("open archive: password required: %v", err)
+        }
+        arc, err = streamline_core.NewArchiveWithOptions(readerAt, readerAt.Size(), streamline_core.ArchiveOptions{Password: archivePassword})
+    }
     if err != nil {
-        log.Fatalf("zip reader: %v", err)
+        log.Fatalf("open archive: %v", err)
     }
+    defer func() { arc.Close() }()
+    log.Printf("Archive format: %s", arc.Format())
 
     if *listMode {
-        log.Println("Archive contents:")
-        for _, f := range zr.File {
-            log.Printf(" - %s (%d bytes)", f.Name, f.UncompressedSize64)
+        all, err := arc.List()
+        if err != nil {
+            log.Fatalf("list archive: %v", err)
         }
+        printEntries(all, filter, *listFormat)
         return
     }
 
-    totalFiles := len(zr.File)
-    skippedCount := 0
-    var errorList []ExtractionError
-    var progressCount int32
+    if *verifyMode {
+        var errorList []ExtractionError
+        summary, err := streamline_core.Verify(ctx, arc, *outDir, streamline_core.ExtractOptions{
+            Select: func(e streamline_core.Entry) bool {
+                return filter.Match(e)
+            },
+            Paths: paths,
+            OnEntry: func(e streamline_core.Entry, targetPath string, err error) {
+                if err != nil {
+                    log.Printf("[ERROR] %s: %v", e.Name, err)
+                    errorList = append(errorList, ExtractionError{File: e.Name, Reason: err.Error(), Code: streamline_core.ErrorCode(err)})
+                } else {
+                    log.Printf("OK: %s", targetPath)
+                }
+            },
+        })
+        if err != nil {
+            log.Fatalf("verify: %v", err)
+        }
+        log.Printf("Verification complete. Total: %d, Verified: %d, Skipped: %d, Errors: %d",
+            summary.Total, summary.Verified, summary.Skipped, len(errorList))
+        writeErrorReport(*outDir, errorList)
+        if len(errorList) > 0 {
+            os.Exit(1)
+        }
+        return
+    }
 
-    var wg sync.WaitGroup
-    jobs := make(chan *zip.File, totalFiles)
+    if *toDrive {
+        runExtractToDrive(ctx, svc, arc, *driveFolder, streamline_core.ExtractOptions{
+            ContinueOnError: *skipErrors,
+            Limits: streamline_core.Limits{
+                MaxTotalBytes: *maxTotalMB * 1024 * 1024,
+                MaxEntryBytes: *maxEntryMB * 1024 * 1024,
+                MaxEntries:    *maxEntries,
+                MaxRatio:      *maxRatio,
+                MaxDepth:      *maxDepth,
+            },
+            Select: filter.Match,
+            Paths:  paths,
+        }, retry)
+        return
+    }
 
-    if *boost {
-        for w := 0; w < *workers; w++ {
-            wg.Add(1)
-            go func() {
-                defer wg.Done()
-                for f := range jobs {
-                    targetPath := filepath.Join(*outDir, f.Name)
-                    if !streamline_core.IsPathWithinBase(*outDir, targetPath) {
-                        log.Printf("[ERROR] Illegal file path: %s", targetPath)
-                        continue
-                    }
-                    if err := streamline_core.ExtractFile(f, targetPath); err != nil {
-                        log.Printf("[ERROR] Failed to extract %s: %v", f.Name, err)
-                        if !*skipErrors {
-                            errorList = append(errorList, ExtractionError{File: f.Name, Reason: err.Error()})
-                        }
-                    } else {
-                        log.Printf("Extracted: %s", targetPath)
-                    }
-                    atomic.AddInt32(&progressCount, 1)
-                    util.PrintProgress(int(progressCount), totalFiles)
-                }
-            }()
+    var checkpoint *streamline_core.Checkpoint
+    if *resume {
+        checkpoint, err = streamline_core.LoadCheckpointWithPaths(*outDir, *fileID, paths)
+        if err != nil {
+            log.Fatalf("load checkpoint: %v", err)
+        }
+        defer checkpoint.Close()
+        if n := checkpoint.Len(); n > 0 {
+            log.Printf("Resuming: %d entries already complete", n)
         }
     }
 
-    if *boost {
-        for _, f := range zr.File {
-            if !streamline_core.ShouldExtract(f.Name, *include, *exclude) {
-                log.Printf("Skipping (filtered): %s", f.Name)
-                skippedCount++
-                continue
-            }
-            jobs <- f
+    // Only indexed formats know their entry count up front; tarballs would
+    // need an extra pass over the whole stream.
+    totalFiles := 0
+    if streamline_core.SupportsRandomAccess(arc) {
+        entries, err := arc.List()
+        if err != nil {
+            log.Fatalf("list archive: %v", err)
         }
-        close(jobs)
-        wg.Wait()
-    } else {
-        for i, f := range zr.File {
-            log.Printf("Extracting file %d of %d: %s", i+1, totalFiles, f.Name)
-            if !streamline_core.ShouldExtract(f.Name, *include, *exclude) {
-                log.Printf("Skipping (filtered): %s", f.Name)
-                skippedCount++
-                continue
+        totalFiles = len(entries)
+        if n := countEncrypted(entries); n > 0 && archivePassword == "" {
+            log.Printf("%d of %d entries are encrypted", n, totalFiles)
+            if archivePassword, err = promptPassword("Archive password: "); err != nil {
+                log.Printf("[WARN] No password available (%v); encrypted entries will fail", err)
+            } else {
+                arc.Close()
+                arc, err = streamline_core.NewArchiveWithOptions(readerAt, readerAt.Size(), streamline_core.ArchiveOptions{Password: archivePassword})
+                if err != nil {
+                    log.Fatalf("open archive: %v", err)
+                }
             }
-            targetPath := filepath.Join(*outDir, f.Name)
-            if !streamline_core.IsPathWithinBase(*outDir, targetPath) {
-                log.Printf("[ERROR] Illegal file path: %s", targetPath)
-                continue
+        }
+    } else if *boost {
+        log.Printf("%s archives are extracted sequentially; ignoring -boost", arc.Format())
+    }
+
+    var errorList []ExtractionError
+    var mu sync.Mutex
+    var progressCount int32
+
+    progress := func() {
+        n := int(atomic.AddInt32(&progressCount, 1))
+        if totalFiles > 0 {
+            util.PrintProgress(n, totalFiles)
+        }
+    }
+
+    opts := streamline_core.ExtractOptions{
+        ContinueOnError: true,
+        Limits: streamline_core.Limits{
+            MaxTotalBytes: *maxTotalMB * 1024 * 1024,
+            MaxEntryBytes: *maxEntryMB * 1024 * 1024,
+            MaxEntries:    *maxEntries,
+            MaxRatio:      *maxRatio,
+            MaxDepth:      *maxDepth,
+        },
+        PreserveModes:    *preserveModes,
+        PreserveTimes:    *preserveTimes,
+        PreserveSymlinks: *preserveSymlinks,
+        Conflict:         conflictPolicy,
+        Paths:            paths,
+        Checkpoint:       checkpoint,
+        Select: func(e streamline_core.Entry) bool {
+            if !filter.Match(e) {
+                log.Printf("Skipping (filtered): %s", e.Name)
+                progress()
+                return false
             }
-            if err := streamline_core.ExtractFile(f, targetPath); err != nil {
-                log.Printf("[ERROR] Failed to extract %s: %v", f.Name, err)
-                if !*skipErrors {
-                    errorList = append(errorList, ExtractionError{File: f.Name, Reason: err.Error()})
+            log.Printf("Extracting: %s", e.Name)
+            return true
+        },
+        OnEntry: func(e streamline_core.Entry, targetPath string, err error) {
+            if errors.Is(err, streamline_core.ErrAlreadyComplete) {
+                log.Printf("Skipping (already complete): %s", targetPath)
+            } else if errors.Is(err, streamline_core.ErrSkippedExisting) {
+                log.Printf("Skipping (already exists): %s", targetPath)
+            } else if err != nil {
+                log.Printf("[ERROR] Failed to extract %s: %v", e.Name, err)
+                // Entries cut short by an aborting error are not failures of their own.
+                if !*skipErrors && ctx.Err() == nil && !errors.Is(err, context.Canceled) {
+                    mu.Lock()
+                    errorList = append(errorList, ExtractionError{File: e.Name, Reason: err.Error(), Code: streamline_core.ErrorCode(err)})
+                    mu.Unlock()
                 }
             } else {
                 log.Printf("Extracted: %s", targetPath)
             }
-            util.PrintProgress(i+1, totalFiles)
-        }
+            progress()
+        },
     }
+    if *boost {
+        opts.Workers = *workers
+    }
+
+    summary, err := arc.Extract(ctx, *outDir, opts)
+    if err != nil && ctx.Err() == nil {
+        log.Printf("[ERROR] Extraction aborted: %v", err)
+    }
+    resumedCount := summary.Resumed
+    skippedCount := summary.Skipped - resumedCount
 
-    log.Printf("Extraction complete. Total: %d, Skipped: %d, Errors: %d",
-        totalFiles, skippedCount, len(errorList))
+    if ctx.Err() != nil {
+        log.Printf("Extraction interrupted; re-run with the same -fileId/-out to resume")
+    }
+    log.Printf("Extraction complete. Total: %d, Skipped: %d, Resumed: %d, Errors: %d",
+        summary.Total, skippedCount, resumedCount, len(errorList))
+    if len(summary.Conflicts) > 0 {
+        actions := make(map[streamline_core.ConflictAction]int)
+        for _, c := range summary.Conflicts {
+            actions[c.Action]++
+        }
+        log.Printf("Existing files (-onConflict=%s): %d overwritten, %d skipped, %d renamed, %d failed",
+            conflictPolicy, actions[streamline_core.ActionOverwritten], actions[streamline_core.ActionSkipped],
+            actions[streamline_core.ActionRenamed], actions[streamline_core.ActionFailed])
+    }
 
     //Error Reporting
-    if len(errorList) > 0 {
-        errFile := filepath.Join(*outDir, "errors.json")
-        f, err := os.Create(errFile)
+    writeErrorReport(*outDir, errorList)
+}
+
+// writeErrorReport saves per-entry failures to errors.json under outDir.
+func writeErrorReport(outDir string, errorList []ExtractionError) {
+    if len(errorList) == 0 {
+        return
+    }
+    errFile := filepath.Join(outDir, "errors.json")
+    f, err := os.Create(errFile)
+    if err != nil {
+        log.Printf("[ERROR] Failed to write error log: %v", err)
+        return
+    }
+    defer f.Close()
+    enc := json.NewEncoder(f)
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(errorList); err != nil {
+        log.Printf("[ERROR] Failed to encode error log: %v", err)
+    } else {
+        log.Printf("❌ %d extraction errors written to %s", len(errorList), errFile)
+    }
+}
+
+// patternList collects a repeatable string flag.
+type patternList []string
+
+func (l *patternList) String() string { return strings.Join(*l, ",") }
+
+func (l *patternList) Set(v string) error {
+    *l = append(*l, v)
+    return nil
+}
+
+// buildFilter combines the filter file with the command-line rules, which
+// come last and so take precedence.
+func buildFilter(file string, includes, excludes []string, minSize, maxSize, after, before string) (*streamline_core.Filter, error) {
+    filter := &streamline_core.Filter{}
+    if path := streamline_core.ResolveFilterFile(file); path != "" {
+        loaded, err := streamline_core.LoadFilterFile(path)
         if err != nil {
-            log.Printf("[ERROR] Failed to write error log: %v", err)
-        } else {
-            enc := json.NewEncoder(f)
-            enc.SetIndent("", "  ")
-            if err := enc.Encode(errorList); err != nil {
-                log.Printf("[ERROR] Failed to encode error log: %v", err)
-            } else {
-                log.Printf("❌ %d extraction errors written to %s", len(errorList), errFile)
+            return nil, err
+        }
+        log.Printf("Using filter rules from %s", path)
+        filter = loaded
+    }
+    flags := &streamline_core.Filter{Include: includes, Exclude: excludes}
+    var err error
+    if minSize != "" {
+        if flags.MinSize, err = streamline_core.ParseSize(minSize); err != nil {
+            return nil, fmt.Errorf("-minSize: %w", err)
+        }
+    }
+    if maxSize != "" {
+        if flags.MaxSize, err = streamline_core.ParseSize(maxSize); err != nil {
+            return nil, fmt.Errorf("-maxSize: %w", err)
+        }
+    }
+    if after != "" {
+        if flags.After, err = streamline_core.ParseDate(after); err != nil {
+            return nil, fmt.Errorf("-after: %w", err)
+        }
+    }
+    if before != "" {
+        if flags.Before, err = streamline_core.ParseDate(before); err != nil {
+            return nil, fmt.Errorf("-before: %w", err)
+        }
+    }
+    filter.Merge(flags)
+    return filter, nil
+}
+
+// buildHTTPOptions parses the -header, -cookie and -user flags.
+func buildHTTPOptions(headers, cookies []string, user string) (downloader.HTTPOptions, error) {
+    var opts downloader.HTTPOptions
+    for _, h := range headers {
+        name, value, ok := strings.Cut(h, ":")
+        name = strings.TrimSpace(name)
+        if !ok || name == "" || strings.ContainsAny(name, " \t") {
+            return opts, fmt.Errorf("-header: want \"Name: value\", got %q", h)
+        }
+        if opts.Header == nil {
+            opts.Header = http.Header{}
+        }
+        opts.Header.Add(name, strings.TrimSpace(value))
+    }
+    for _, c := range cookies {
+        name, value, ok := strings.Cut(c, "=")
+        if !ok || strings.TrimSpace(name) == "" {
+            return opts, fmt.Errorf("-cookie: want name=value, got %q", c)
+        }
+        opts.Cookies = append(opts.Cookies, &http.Cookie{Name: strings.TrimSpace(name), Value: value})
+    }
+    if user != "" {
+        name, pass, ok := strings.Cut(user, ":")
+        if !ok {
+            var err error
+            if pass, err = promptPassword("Password for " + name + ": "); err != nil {
+                return opts, fmt.Errorf("-user: %w", err)
             }
-            f.Close()
         }
+        opts.Username, opts.Password = name, pass
+    }
+    return opts, nil
+}
+
+// checkExtractFlags validates the flags of a -fileId extraction, which
+// writes under -out unless -toDrive sends the entries to Drive instead.
+func checkExtractFlags(fileID, outDir string, toDrive, verify bool) error {
+    switch {
+    case fileID == "":
+        return fmt.Errorf("Usage: %s -fileId <ID> (-out <path> | -toDrive) [-chunkMB N]", os.Args[0])
+    case toDrive && verify:
+        return fmt.Errorf("-verify checks files under -out and cannot be combined with -toDrive")
+    case outDir == "" && !toDrive:
+        return fmt.Errorf("-out is required unless -toDrive extracts into Drive")
     }
+    return nil
+}
 
+// splitIDs parses the comma-separated -fileId value.
+func splitIDs(s string) []string {
+    var ids []string
+    for _, id := range strings.Split(s, ",") {
+        if id = strings.TrimSpace(id); id != "" {
+            ids = append(ids, id)
+        }
+    }
+    return ids
 }
 
+func countEncrypted(entries []streamline_core.Entry) int {
+    n := 0
+    for _, e := range entries {
+        if e.Encrypted {
+            n++
+        }
+    }
+    return n
+}
+
+// promptPassword reads a password from the terminal without echoing it.
+func promptPassword(prompt string) (string, error) {
+    fd := int(os.Stdin.Fd())
+    if !term.IsTerminal(fd) {
+        return "", fmt.Errorf("stdin is not a terminal")
+    }
+    fmt.Fprint(os.Stderr, prompt)
+    b, err := term.ReadPassword(fd)
+    fmt.Fprintln(os.Stderr)
+    if err != nil {
+        return "", err
+    }
+    return string(b), nil
+}
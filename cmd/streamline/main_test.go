@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestCheckExtractFlags(t *testing.T) {
+    tests := []struct {
+        name            string
+        fileID, outDir  string
+        toDrive, verify bool
+        ok              bool
+    }{
+        {"to Drive without -out", "X", "", true, false, true},
+        {"local extraction", "X", "out", false, false, true},
+        {"no destination", "X", "", false, false, false},
+        {"no archive", "", "out", false, false, false},
+        {"verify to Drive", "X", "out", true, true, false},
+    }
+    for _, tt := range tests {
+        err := checkExtractFlags(tt.fileID, tt.outDir, tt.toDrive, tt.verify)
+        if (err == nil) != tt.ok {
+            t.Errorf("%s: checkExtractFlags = %v; want ok=%v", tt.name, err, tt.ok)
+        }
+    }
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	streamline_core "Streamline/cmd/streamline_core"
+	"Streamline/internal/auth"
+	downloader "Streamline/internal/downloader"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// runPack handles -pack. Drive is only signed in to when the source or the
+// destination is there, so packing a local directory to disk needs no
+// credentials.
+func runPack(ctx context.Context, p *downloader.Packer, driveFolder string) {
+    var svc *drive.Service
+    if p.FolderID != "" || p.OutPath == "" {
+        httpClient, err := auth.GetClient(ctx)
+        if err != nil {
+            log.Fatalf("auth client: %v", err)
+        }
+        if svc, err = drive.NewService(ctx, option.WithHTTPClient(httpClient)); err != nil {
+            log.Fatalf("drive service: %v", err)
+        }
+    }
+    p.Options.OnEntry = func(e streamline_core.Entry, name string, err error) {
+        if err != nil {
+            log.Printf("[ERROR] Failed to pack %s: %v", e.Name, err)
+        } else if verbose {
+            log.Printf("Packed: %s", name)
+        }
+    }
+
+    res, err := p.Pack(ctx, svc, driveFolder)
+    if res != nil && res.Summary != nil {
+        log.Printf("Pack complete. Total: %d, Packed: %d, Skipped: %d, Errors: %d",
+            res.Summary.Total, res.Summary.Extracted, res.Summary.Skipped, len(res.Summary.Errors))
+    }
+    if err != nil {
+        log.Fatalf("pack: %v", err)
+    }
+    if res.FileID != "" {
+        log.Printf("✅ Uploaded archive (%d bytes). File ID: %s", res.Bytes, res.FileID)
+        fmt.Println(res.FileID)
+    } else {
+        log.Printf("✅ Wrote %s (%d bytes)", res.Path, res.Bytes)
+    }
+    if len(res.Summary.Errors) > 0 {
+        os.Exit(1)
+    }
+}
@@ -0,0 +1,598 @@
+package streamline_core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format identifies an archive container (and its outer compression).
+type Format string
+
+const (
+    FormatZip    Format = "zip"
+    FormatTar    Format = "tar"
+    FormatTarGz  Format = "tar.gz"
+    FormatTarBz2 Format = "tar.bz2"
+    FormatTarXz  Format = "tar.xz"
+    FormatTarZst Format = "tar.zst"
+    Format7z     Format = "7z"
+    FormatRar    Format = "rar"
+)
+
+// ErrUnsupportedFormat is returned when sniffing finds no known archive.
+var ErrUnsupportedFormat = errors.New("unsupported archive format")
+
+// Entry describes one member of an archive, independent of its format.
+type Entry struct {
+    Name           string // slash-separated path inside the archive
+    Size           int64  // uncompressed size, or -1 if the archive doesn't record it
+    CompressedSize int64
+    Mode           os.FileMode
+    Modified       time.Time
+    IsDir          bool
+    CRC32          uint32
+    HasCRC         bool // CRC32 is only meaningful when set
+    Encrypted      bool // reading the contents needs ArchiveOptions.Password
+    Linkname       string // symlink target, when the format stores it in the header
+    Method         string // compression method, e.g. "deflate"; empty if not recorded per entry
+    Comment        string
+}
+
+// ArchiveOptions configures how an archive is opened.
+type ArchiveOptions struct {
+    // Password decrypts ZipCrypto and WinZip AES ZIP entries, encrypted 7z
+    // archives and encrypted RAR archives.
+    Password string
+}
+
+// Archive is a readable archive of any supported format.
+type Archive interface {
+    Format() Format
+    // List returns the archive's entries in archive order.
+    List() ([]Entry, error)
+    // Open returns the contents of the named entry.
+    Open(name string) (io.ReadCloser, error)
+    // Extract writes entries under outDir according to opts.
+    Extract(ctx context.Context, outDir string, opts ExtractOptions) (*ExtractSummary, error)
+    Close() error
+}
+
+// ExtractOptions controls Archive.Extract. Hooks may be called from several
+// goroutines at once when Workers > 1.
+type ExtractOptions struct {
+    // Select reports whether an entry should be extracted; nil selects all.
+    Select func(e Entry) bool
+    // OnEntry is called after each selected entry with its target path and
+    // the extraction error, if any. Entries left alone by the conflict
+    // policy report ErrSkippedExisting; those skipped by Checkpoint,
+    // ErrAlreadyComplete.
+    OnEntry func(e Entry, targetPath string, err error)
+    // ContinueOnError records per-entry failures in the summary and keeps
+    // going instead of aborting on the first one.
+    ContinueOnError bool
+    // Workers extracts entries in parallel for formats that allow random
+    // access (ZIP, 7z). Other formats are always extracted sequentially.
+    Workers int
+    // Limits guards against decompression bombs. Exceeding the total-bytes
+    // or entry-count limit aborts the extraction even with ContinueOnError.
+    Limits Limits
+    // PreserveModes applies archived permission bits to files and dirs.
+    PreserveModes bool
+    // PreserveTimes applies archived modification times.
+    PreserveTimes bool
+    // PreserveSymlinks recreates symlink entries as symlinks instead of
+    // regular files holding the target path. Targets must stay inside
+    // outDir, and nothing is written through a link that leaves it.
+    PreserveSymlinks bool
+    // Conflict decides what happens to files that already exist at an
+    // entry's target; the zero value overwrites them.
+    Conflict ConflictPolicy
+    // Checkpoint, when set, skips entries a previous run recorded as
+    // complete, reporting ErrAlreadyComplete, and records each entry this
+    // run completes. Entries are keyed by their mapped output path.
+    Checkpoint *Checkpoint
+    // Paths remaps entry paths below outDir; OnEntry sees the final path.
+    Paths PathMapping
+}
+
+// EntryError is a failure to extract a single entry.
+type EntryError struct {
+    Name string
+    Err  error
+}
+
+func (e *EntryError) Error() string { return fmt.Sprintf("extract %s: %v", e.Name, e.Err) }
+func (e *EntryError) Unwrap() error { return e.Err }
+
+// ExtractSummary reports what Archive.Extract did.
+type ExtractSummary struct {
+    Total     int
+    Extracted int
+    Skipped   int
+    Resumed   int // of Skipped, those a Checkpoint had as complete
+    Errors    []*EntryError
+    Conflicts []Conflict // one per entry whose target already existed
+}
+
+// entryOpener opens an entry's contents. For streaming formats it is only
+// valid during the walk callback that received it.
+type entryOpener func() (io.ReadCloser, error)
+
+// walker is the format-specific part every Archive implementation provides;
+// extraction on top of it is shared.
+type walker interface {
+    walk(ctx context.Context, fn func(e Entry, open entryOpener) error) error
+    randomAccess() bool
+}
+
+// SupportsRandomAccess reports whether entries of a can be opened in any
+// order without rescanning the archive.
+func SupportsRandomAccess(a Archive) bool {
+    w, ok := a.(walker)
+    return ok && w.randomAccess()
+}
+
+// OpenArchive opens a local archive file, sniffing its format.
+func OpenArchive(path string) (Archive, error) {
+    return OpenArchiveWithOptions(path, ArchiveOptions{})
+}
+
+// OpenArchiveWithOptions is OpenArchive with a password or other options.
+// Naming any disk of a split ZIP (.z01 ... .zip, or .zip.001 ...) or any
+// volume of a multi-volume RAR opens the whole set.
+func OpenArchiveWithOptions(path string, opts ArchiveOptions) (Archive, error) {
+    parts, err := SplitZipParts(path)
+    if err != nil {
+        return nil, err
+    }
+    if len(parts) > 1 {
+        return openSplitZip(parts, opts)
+    }
+
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("open archive: %w", err)
+    }
+    st, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return nil, fmt.Errorf("stat archive: %w", err)
+    }
+    format, err := DetectFormat(f, st.Size())
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    if format == FormatRar {
+        // Volumes are opened by name so multi-part sets can be followed.
+        f.Close()
+        return newRarFileArchive(path, opts), nil
+    }
+    a, err := newArchive(f, st.Size(), f, opts)
+    if err != nil {
+        f.Close()
+        return nil, err
+    }
+    return a, nil
+}
+
+// NewArchive opens an archive from random-access storage such as a
+// DriveReaderAt, sniffing its format from the leading bytes. Split ZIP
+// parts should be joined with JoinSplitZip first.
+func NewArchive(r io.ReaderAt, size int64) (Archive, error) {
+    return newArchive(r, size, nil, ArchiveOptions{})
+}
+
+// NewArchiveWithOptions is NewArchive with a password or other options.
+func NewArchiveWithOptions(r io.ReaderAt, size int64, opts ArchiveOptions) (Archive, error) {
+    return newArchive(r, size, nil, opts)
+}
+
+func newArchive(r io.ReaderAt, size int64, closer io.Closer, opts ArchiveOptions) (Archive, error) {
+    format, err := DetectFormat(r, size)
+    if err != nil {
+        return nil, err
+    }
+    switch format {
+    case FormatZip:
+        return newZipArchive(r, size, closer, opts)
+    case Format7z:
+        return newSevenZipArchive(r, size, closer, opts)
+    case FormatRar:
+        return newRarArchive(r, size, closer, opts), nil
+    default:
+        return newTarArchive(format, r, size, closer), nil
+    }
+}
+
+var (
+    magicZip   = []byte("PK\x03\x04")
+    magicZipE  = []byte("PK\x05\x06") // empty archive
+    magicZipSp = []byte("PK\x07\x08") // spanned archive marker
+    magicGzip  = []byte{0x1f, 0x8b}
+    magicBzip2 = []byte("BZh")
+    magicXz    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+    magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+    magic7z    = []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}
+    magicRar4  = []byte("Rar!\x1a\x07\x00")
+    magicRar5  = []byte("Rar!\x1a\x07\x01\x00")
+)
+
+// DetectFormat sniffs the archive format from magic bytes. Compressed
+// streams are decompressed far enough to confirm they contain a tar.
+func DetectFormat(r io.ReaderAt, size int64) (Format, error) {
+    head := make([]byte, minInt64(size, 512))
+    if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+        return "", fmt.Errorf("read archive header: %w", err)
+    }
+
+    var compressed Format
+    switch {
+    case bytes.HasPrefix(head, magicZip), bytes.HasPrefix(head, magicZipE), bytes.HasPrefix(head, magicZipSp):
+        return FormatZip, nil
+    case bytes.HasPrefix(head, magic7z):
+        return Format7z, nil
+    case bytes.HasPrefix(head, magicRar4), bytes.HasPrefix(head, magicRar5):
+        return FormatRar, nil
+    case bytes.HasPrefix(head, magicGzip):
+        compressed = FormatTarGz
+    case bytes.HasPrefix(head, magicBzip2):
+        compressed = FormatTarBz2
+    case bytes.HasPrefix(head, magicXz):
+        compressed = FormatTarXz
+    case bytes.HasPrefix(head, magicZstd):
+        compressed = FormatTarZst
+    case isTarHeader(head):
+        return FormatTar, nil
+    default:
+        // Self-extracting and prefixed ZIPs only reveal themselves at the end.
+        if _, err := newZipArchive(r, size, nil, ArchiveOptions{}); err == nil {
+            return FormatZip, nil
+        } else if errors.Is(err, ErrSplitArchive) {
+            return "", err
+        }
+        return "", ErrUnsupportedFormat
+    }
+
+    rc, err := decompress(compressed, io.NewSectionReader(r, 0, size))
+    if err != nil {
+        return "", fmt.Errorf("%s: %w", compressed, err)
+    }
+    defer rc.Close()
+    inner := make([]byte, 512)
+    if _, err := io.ReadFull(rc, inner); err != nil || !isTarHeader(inner) {
+        return "", fmt.Errorf("%w: %s stream does not contain a tar archive", ErrUnsupportedFormat, compressed)
+    }
+    return compressed, nil
+}
+
+// isTarHeader recognises ustar/GNU headers by magic and falls back to the
+// header checksum for old V7 archives.
+func isTarHeader(b []byte) bool {
+    if len(b) < 512 {
+        return false
+    }
+    if bytes.Equal(b[257:262], []byte("ustar")) {
+        return true
+    }
+    field := strings.TrimRight(strings.TrimSpace(string(b[148:156])), "\x00")
+    want, err := strconv.ParseInt(strings.TrimSpace(field), 8, 64)
+    if err != nil {
+        return false
+    }
+    var sum int64
+    for i, c := range b[:512] {
+        if i >= 148 && i < 156 {
+            c = ' '
+        }
+        sum += int64(c)
+    }
+    return sum == want && sum != 8*' '
+}
+
+func minInt64(a, b int64) int64 {
+    if a < b {
+        return a
+    }
+    return b
+}
+
+// entryExtractor holds the per-extraction state shared by every entry.
+type entryExtractor struct {
+    guard     *limitGuard
+    meta      *preserver
+    conflicts *conflictResolver
+}
+
+// extract writes one entry that has already passed the path check and
+// returns where it went, which differs from targetPath after a rename.
+func (x *entryExtractor) extract(ctx context.Context, e Entry, open entryOpener, targetPath string) (string, *Conflict, error) {
+    if err := x.guard.admit(e); err != nil {
+        return targetPath, nil, err
+    }
+    targetPath, conflict, err := x.conflicts.resolve(e, targetPath)
+    if err != nil {
+        return targetPath, conflict, err
+    }
+    if err := x.meta.checkParent(targetPath); err != nil {
+        return targetPath, conflict, err
+    }
+    if x.meta.symlinks && e.IsSymlink() {
+        return targetPath, conflict, x.meta.symlink(ctx, e, open, targetPath)
+    }
+    if err := writeEntry(ctx, e, x.guard.opener(e, open), targetPath); err != nil {
+        return targetPath, conflict, err
+    }
+    return targetPath, conflict, x.meta.apply(e, targetPath)
+}
+
+// extractArchive is the Extract implementation shared by every format.
+func extractArchive(ctx context.Context, w walker, outDir string, opts ExtractOptions) (*ExtractSummary, error) {
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    summary := &ExtractSummary{}
+    meta := newPreserver(outDir, opts)
+    x := &entryExtractor{
+        guard:     newLimitGuard(opts.Limits).meter(w),
+        meta:      meta,
+        conflicts: newConflictResolver(opts.Conflict),
+    }
+    var (
+        mu       sync.Mutex
+        firstErr error
+    )
+
+    process := func(e Entry, name string, open entryOpener) {
+        targetPath := filepath.Join(outDir, filepath.FromSlash(name))
+        var (
+            conflict *Conflict
+            err      error
+        )
+        cp := opts.Checkpoint
+        switch {
+        case strings.HasPrefix(name, "/") || !IsPathWithinBase(outDir, targetPath):
+            err = fmt.Errorf("illegal path: %s", name)
+        case cp != nil && cp.IsComplete(name, e.CRC32, e.Size):
+            err = ErrAlreadyComplete
+        default:
+            targetPath, conflict, err = x.extract(ctx, e, open, targetPath)
+            if cp != nil && (err == nil || errors.Is(err, ErrSkippedExisting)) {
+                // Losing a record only costs extracting the entry again.
+                // Entries of unknown size record the size written.
+                size := e.Size
+                if size < 0 {
+                    if st, err := os.Stat(targetPath); err == nil {
+                        size = st.Size()
+                    }
+                }
+                if size >= 0 {
+                    cp.MarkComplete(name, e.CRC32, uint64(size))
+                }
+            }
+        }
+        if opts.OnEntry != nil {
+            opts.OnEntry(e, targetPath, err)
+        }
+
+        mu.Lock()
+        defer mu.Unlock()
+        if conflict != nil {
+            summary.Conflicts = append(summary.Conflicts, *conflict)
+        }
+        if errors.Is(err, ErrAlreadyComplete) {
+            summary.Resumed++
+        }
+        if errors.Is(err, ErrSkippedExisting) || errors.Is(err, ErrAlreadyComplete) {
+            summary.Skipped++
+            return
+        }
+        if err == nil {
+            summary.Extracted++
+            return
+        }
+        summary.Errors = append(summary.Errors, &EntryError{Name: e.Name, Err: err})
+        var lerr *LimitError
+        fatal := errors.As(err, &lerr) && lerr.fatal()
+        if (!opts.ContinueOnError || fatal) && firstErr == nil {
+            firstErr = &EntryError{Name: e.Name, Err: err}
+            cancel()
+        }
+    }
+
+    // Called from the walk only, so path mapping sees entries in order.
+    mapper := newPathMapper(opts.Paths)
+    selectEntry := func(e Entry) (string, bool) {
+        mu.Lock()
+        summary.Total++
+        mu.Unlock()
+        name, ok := "", opts.Select == nil || opts.Select(e)
+        if ok {
+            name, ok = mapper.mapName(e)
+        }
+        if !ok {
+            mu.Lock()
+            summary.Skipped++
+            mu.Unlock()
+        }
+        return name, ok
+    }
+
+    var walkErr error
+    if opts.Workers > 1 && w.randomAccess() {
+        type job struct {
+            e    Entry
+            name string
+            open entryOpener
+        }
+        jobs := make(chan job)
+        var wg sync.WaitGroup
+        for i := 0; i < opts.Workers; i++ {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                for j := range jobs {
+                    process(j.e, j.name, j.open)
+                }
+            }()
+        }
+        walkErr = w.walk(ctx, func(e Entry, open entryOpener) error {
+            name, ok := selectEntry(e)
+            if !ok {
+                return nil
+            }
+            select {
+            case jobs <- job{e, name, open}:
+                return nil
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        })
+        close(jobs)
+        wg.Wait()
+    } else {
+        walkErr = w.walk(ctx, func(e Entry, open entryOpener) error {
+            name, ok := selectEntry(e)
+            if !ok {
+                return nil
+            }
+            process(e, name, open)
+            return ctx.Err()
+        })
+    }
+
+    if err := meta.finish(); err != nil {
+        summary.Errors = append(summary.Errors, err.(*EntryError))
+        if firstErr == nil && !opts.ContinueOnError {
+            firstErr = err
+        }
+    }
+    if firstErr != nil {
+        return summary, firstErr
+    }
+    if walkErr != nil {
+        return summary, walkErr
+    }
+    return summary, nil
+}
+
+// ExtractEntry extracts a single entry of a to targetPath. The caller is
+// responsible for validating targetPath (see IsPathWithinBase).
+func ExtractEntry(ctx context.Context, a Archive, e Entry, targetPath string) error {
+    return writeEntry(ctx, e, func() (io.ReadCloser, error) { return a.Open(e.Name) }, targetPath)
+}
+
+// writeEntry materialises one entry at targetPath.
+func writeEntry(ctx context.Context, e Entry, open entryOpener, targetPath string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    if e.IsDir {
+        return os.MkdirAll(targetPath, 0o755)
+    }
+    if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+        return fmt.Errorf("mkdir parents: %w", err)
+    }
+    rc, err := open()
+    if err != nil {
+        return fmt.Errorf("open entry: %w", err)
+    }
+    defer rc.Close()
+
+    // Write beside the target and rename only once the contents check out,
+    // so an interrupted or corrupt entry never looks complete.
+    tmp, err := createTemp(targetPath)
+    if err != nil {
+        return fmt.Errorf("create file: %w", err)
+    }
+    committed := false
+    defer func() {
+        if !committed {
+            tmp.Close()
+            os.Remove(tmp.Name())
+        }
+    }()
+    hash := crc32.NewIEEE()
+    n, err := io.CopyBuffer(io.MultiWriter(tmp, hash), NewContextReader(ctx, rc), make([]byte, BufferSize))
+    if err != nil {
+        return fmt.Errorf("write file: %w", err)
+    }
+    if err := checkContents(e, n, hash.Sum32()); err != nil {
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return fmt.Errorf("write file: %w", err)
+    }
+    if err := os.Rename(tmp.Name(), targetPath); err != nil {
+        return fmt.Errorf("rename into place: %w", err)
+    }
+    committed = true
+    return nil
+}
+
+// createTemp creates a hidden file next to targetPath. Unlike
+// os.CreateTemp it leaves permissions to the umask, as os.Create would.
+func createTemp(targetPath string) (*os.File, error) {
+    dir, base := filepath.Split(targetPath)
+    for {
+        name := filepath.Join(dir, "."+base+"."+strconv.FormatUint(rand.Uint64(), 36)+".partial")
+        f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+        if !errors.Is(err, fs.ErrExist) {
+            return f, err
+        }
+    }
+}
+
+// errFound stops a walk once openByWalk has located its entry.
+var errFound = errors.New("found")
+
+// openByWalk implements Open for streaming formats. Their readers are only
+// valid during a walk, so the entry is handed over through a pipe and the
+// walk stays parked until the caller has read or closed it.
+func openByWalk(w walker, name string) (io.ReadCloser, error) {
+    pr, pw := io.Pipe()
+    found := make(chan struct{})
+    go func() {
+        err := w.walk(context.Background(), func(e Entry, open entryOpener) error {
+            if e.Name != name || e.IsDir {
+                return nil
+            }
+            close(found)
+            rc, err := open()
+            if err != nil {
+                return err
+            }
+            defer rc.Close()
+            if _, err := io.Copy(pw, rc); err != nil {
+                return err
+            }
+            return errFound
+        })
+        select {
+        case <-found:
+        default:
+            if err == nil {
+                err = fmt.Errorf("open %s: %w", name, os.ErrNotExist)
+            }
+            close(found)
+        }
+        if err == errFound {
+            err = nil
+        }
+        pw.CloseWithError(err)
+    }()
+    <-found
+    return pr, nil
+}
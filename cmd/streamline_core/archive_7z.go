@@ -0,0 +1,113 @@
+package streamline_core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bodgit/sevenzip"
+)
+
+type sevenZipArchive struct {
+    zr       *sevenzip.Reader
+    closer   io.Closer
+    index    map[string]*sevenzip.File
+    password string
+}
+
+func newSevenZipArchive(r io.ReaderAt, size int64, closer io.Closer, opts ArchiveOptions) (*sevenZipArchive, error) {
+    zr, err := sevenzip.NewReaderWithPassword(r, size, opts.Password)
+    if err != nil {
+        return nil, fmt.Errorf("7z reader: %w", sevenZipError(err, opts.Password))
+    }
+    index := make(map[string]*sevenzip.File, len(zr.File))
+    for _, f := range zr.File {
+        if _, dup := index[f.Name]; !dup {
+            index[f.Name] = f
+        }
+    }
+    return &sevenZipArchive{zr: zr, closer: closer, index: index, password: opts.Password}, nil
+}
+
+func sevenZipEntry(f *sevenzip.File) Entry {
+    info := f.FileInfo()
+    e := Entry{
+        Name:     f.Name,
+        Size:     int64(f.UncompressedSize),
+        Mode:     info.Mode(),
+        Modified: f.Modified,
+        IsDir:    info.IsDir(),
+        CRC32:    f.CRC32,
+        HasCRC:   f.CRC32 != 0 || f.UncompressedSize == 0,
+    }
+    if e.IsDir && len(e.Name) > 0 && e.Name[len(e.Name)-1] != '/' {
+        e.Name += "/"
+    }
+    return e
+}
+
+func (a *sevenZipArchive) Format() Format     { return Format7z }
+func (a *sevenZipArchive) randomAccess() bool { return true }
+
+func (a *sevenZipArchive) List() ([]Entry, error) {
+    entries := make([]Entry, 0, len(a.zr.File))
+    for _, f := range a.zr.File {
+        entries = append(entries, sevenZipEntry(f))
+    }
+    return entries, nil
+}
+
+func (a *sevenZipArchive) Open(name string) (io.ReadCloser, error) {
+    f, ok := a.index[name]
+    if !ok {
+        return nil, fmt.Errorf("open %s: %w", name, os.ErrNotExist)
+    }
+    return a.open(f)
+}
+
+func (a *sevenZipArchive) open(f *sevenzip.File) (io.ReadCloser, error) {
+    rc, err := f.Open()
+    if err != nil {
+        return nil, sevenZipError(err, a.password)
+    }
+    return rc, nil
+}
+
+// sevenZipError maps the library's encryption hints onto ErrPasswordRequired
+// and ErrBadPassword. 7z has no password check value, so a wrong password
+// only shows up as corrupt data.
+func sevenZipError(err error, password string) error {
+    var rerr *sevenzip.ReadError
+    if !errors.As(err, &rerr) || !rerr.Encrypted {
+        return err
+    }
+    if password == "" {
+        return fmt.Errorf("%w: %v", ErrPasswordRequired, err)
+    }
+    return fmt.Errorf("%w: %v", ErrBadPassword, err)
+}
+
+func (a *sevenZipArchive) walk(ctx context.Context, fn func(e Entry, open entryOpener) error) error {
+    for _, f := range a.zr.File {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if err := fn(sevenZipEntry(f), func() (io.ReadCloser, error) { return a.open(f) }); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (a *sevenZipArchive) Extract(ctx context.Context, outDir string, opts ExtractOptions) (*ExtractSummary, error) {
+    return extractArchive(ctx, a, outDir, opts)
+}
+
+func (a *sevenZipArchive) Close() error {
+    if a.closer != nil {
+        return a.closer.Close()
+    }
+    return nil
+}
@@ -0,0 +1,187 @@
+package streamline_core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nwaples/rardecode/v2"
+)
+
+// rarArchive reads RAR4 and RAR5 archives. RAR (especially solid RAR) is
+// decoded as a stream, so like tar it is walked sequentially and Open
+// rescans up to the requested entry. Local multi-volume sets are followed
+// automatically from the first volume.
+type rarArchive struct {
+    open     func(opts ...rardecode.Option) (*rardecode.Reader, io.Closer, error)
+    closer   io.Closer
+    password string
+
+    once    sync.Once
+    entries []Entry
+    listErr error
+}
+
+// newRarFileArchive opens a local RAR, starting from the first volume of a
+// multi-volume set even if a later part was named.
+func newRarFileArchive(path string, opts ArchiveOptions) *rarArchive {
+    first := firstRarVolume(path)
+    return &rarArchive{
+        password: opts.Password,
+        open: func(opts ...rardecode.Option) (*rardecode.Reader, io.Closer, error) {
+            rc, err := rardecode.OpenReader(first, opts...)
+            if err != nil {
+                return nil, nil, err
+            }
+            return &rc.Reader, rc, nil
+        },
+    }
+}
+
+// newRarArchive reads a single-volume RAR from random-access storage.
+func newRarArchive(r io.ReaderAt, size int64, closer io.Closer, opts ArchiveOptions) *rarArchive {
+    return &rarArchive{
+        closer:   closer,
+        password: opts.Password,
+        open: func(opts ...rardecode.Option) (*rardecode.Reader, io.Closer, error) {
+            rr, err := rardecode.NewReader(io.NewSectionReader(r, 0, size), opts...)
+            if err != nil {
+                return nil, nil, err
+            }
+            return rr, io.NopCloser(nil), nil
+        },
+    }
+}
+
+var (
+    rarPartPattern   = regexp.MustCompile(`(?i)^(.*\.part)(\d+)(\.rar)$`)
+    rarOldVolPattern = regexp.MustCompile(`(?i)^(.*)\.r\d\d$`)
+)
+
+// firstRarVolume maps any volume of a set ("x.part3.rar", "x.r01") to the
+// first one when it exists on disk; other paths are returned unchanged.
+func firstRarVolume(path string) string {
+    dir, name := filepath.Split(path)
+    var candidate string
+    if m := rarPartPattern.FindStringSubmatch(name); m != nil {
+        if n, _ := strconv.Atoi(m[2]); n == 1 {
+            return path
+        }
+        candidate = m[1] + fmt.Sprintf("%0*d", len(m[2]), 1) + m[3]
+    } else if m := rarOldVolPattern.FindStringSubmatch(name); m != nil {
+        candidate = m[1] + ".rar"
+    } else {
+        return path
+    }
+    if _, err := os.Stat(filepath.Join(dir, candidate)); err != nil {
+        return path
+    }
+    return filepath.Join(dir, candidate)
+}
+
+func rarEntry(h *rardecode.FileHeader) Entry {
+    e := Entry{
+        Name:           strings.TrimPrefix(h.Name, "/"),
+        Size:           h.UnPackedSize,
+        CompressedSize: h.PackedSize,
+        Mode:           h.Mode(),
+        Modified:       h.ModificationTime,
+        IsDir:          h.IsDir,
+        Encrypted:      h.Encrypted,
+    }
+    if h.UnKnownSize {
+        e.Size = -1
+    }
+    if e.IsDir && !strings.HasSuffix(e.Name, "/") {
+        e.Name += "/"
+    }
+    return e
+}
+
+func (a *rarArchive) Format() Format     { return FormatRar }
+func (a *rarArchive) randomAccess() bool { return false }
+
+func (a *rarArchive) walk(ctx context.Context, fn func(e Entry, open entryOpener) error) error {
+    var opts []rardecode.Option
+    if a.password != "" {
+        opts = append(opts, rardecode.Password(a.password))
+    }
+    rr, closer, err := a.open(opts...)
+    if err != nil {
+        return fmt.Errorf("rar reader: %w", rarError(err))
+    }
+    defer closer.Close()
+
+    for {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        h, err := rr.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("read rar: %w", rarError(err))
+        }
+        open := func() (io.ReadCloser, error) { return rarReadCloser{rr}, nil }
+        if err := fn(rarEntry(h), open); err != nil {
+            return err
+        }
+    }
+}
+
+func (a *rarArchive) List() ([]Entry, error) {
+    a.once.Do(func() {
+        a.listErr = a.walk(context.Background(), func(e Entry, _ entryOpener) error {
+            a.entries = append(a.entries, e)
+            return nil
+        })
+    })
+    return a.entries, a.listErr
+}
+
+func (a *rarArchive) Open(name string) (io.ReadCloser, error) {
+    return openByWalk(a, name)
+}
+
+func (a *rarArchive) Extract(ctx context.Context, outDir string, opts ExtractOptions) (*ExtractSummary, error) {
+    return extractArchive(ctx, a, outDir, opts)
+}
+
+func (a *rarArchive) Close() error {
+    if a.closer != nil {
+        return a.closer.Close()
+    }
+    return nil
+}
+
+// rarReadCloser maps decryption failures surfaced mid-read.
+type rarReadCloser struct {
+    r io.Reader
+}
+
+func (r rarReadCloser) Read(p []byte) (int, error) {
+    n, err := r.r.Read(p)
+    return n, rarError(err)
+}
+
+func (r rarReadCloser) Close() error { return nil }
+
+// rarError maps rardecode's encryption errors onto ErrPasswordRequired and
+// ErrBadPassword.
+func rarError(err error) error {
+    switch {
+    case errors.Is(err, rardecode.ErrArchiveEncrypted), errors.Is(err, rardecode.ErrArchivedFileEncrypted):
+        return fmt.Errorf("%w: %v", ErrPasswordRequired, err)
+    case errors.Is(err, rardecode.ErrBadPassword):
+        return fmt.Errorf("%w: %v", ErrBadPassword, err)
+    }
+    return err
+}
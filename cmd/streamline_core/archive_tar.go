@@ -0,0 +1,184 @@
+package streamline_core
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// tarArchive reads plain and compressed tarballs. Tar has no index, so
+// List scans the stream once and Open rescans up to the requested entry;
+// Extract makes a single pass.
+type tarArchive struct {
+    format Format
+    r      io.ReaderAt
+    size   int64
+    closer io.Closer
+
+    once    sync.Once
+    entries []Entry
+    listErr error
+
+    read atomic.Int64 // compressed bytes the current walk has consumed
+}
+
+func newTarArchive(format Format, r io.ReaderAt, size int64, closer io.Closer) *tarArchive {
+    return &tarArchive{format: format, r: r, size: size, closer: closer}
+}
+
+// decompress wraps r in the decompressor for a compressed tar format.
+func decompress(format Format, r io.Reader) (io.ReadCloser, error) {
+    switch format {
+    case FormatTar:
+        return io.NopCloser(r), nil
+    case FormatTarGz:
+        return gzip.NewReader(r)
+    case FormatTarBz2:
+        return io.NopCloser(bzip2.NewReader(r)), nil
+    case FormatTarXz:
+        xr, err := xz.NewReader(r)
+        if err != nil {
+            return nil, err
+        }
+        return io.NopCloser(xr), nil
+    case FormatTarZst:
+        zr, err := zstd.NewReader(r)
+        if err != nil {
+            return nil, err
+        }
+        return zr.IOReadCloser(), nil
+    }
+    return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+// unsafeEntryName reports names that are absolute or step up with "..".
+func unsafeEntryName(name string) bool {
+    if strings.HasPrefix(name, "/") {
+        return true
+    }
+    for _, part := range strings.Split(name, "/") {
+        if part == ".." {
+            return true
+        }
+    }
+    return false
+}
+
+// tarEntry converts a tar header into an Entry; ok is false for entry
+// types that are not extracted (links, devices, metadata records).
+func tarEntry(h *tar.Header) (Entry, bool) {
+    // Unsafe names are kept as they are rather than re-rooted, so that
+    // extraction refuses them as illegal paths, as it does ZIP entries.
+    name := strings.TrimSuffix(h.Name, "/")
+    if !unsafeEntryName(name) {
+        name = path.Clean(name)
+    }
+    if name == "." || name == "" {
+        return Entry{}, false
+    }
+    e := Entry{
+        Name:     name,
+        Size:     h.Size,
+        Mode:     h.FileInfo().Mode(),
+        Modified: h.ModTime,
+    }
+    switch h.Typeflag {
+    case tar.TypeDir:
+        e.IsDir = true
+        e.Name += "/"
+        e.Size = 0
+    case tar.TypeReg, tar.TypeRegA, tar.TypeGNUSparse:
+    case tar.TypeSymlink:
+        // Read as a file, a link's contents are its target, as in ZIP.
+        e.Linkname = h.Linkname
+        e.Size = int64(len(h.Linkname))
+    default:
+        return Entry{}, false
+    }
+    e.CompressedSize = e.Size
+    return e, true
+}
+
+func (a *tarArchive) Format() Format     { return a.format }
+func (a *tarArchive) randomAccess() bool { return false }
+
+func (a *tarArchive) walk(ctx context.Context, fn func(e Entry, open entryOpener) error) error {
+    a.read.Store(0)
+    rc, err := decompress(a.format, &meteredReader{r: io.NewSectionReader(a.r, 0, a.size), n: &a.read})
+    if err != nil {
+        return fmt.Errorf("%s: %w", a.format, err)
+    }
+    defer rc.Close()
+
+    tr := tar.NewReader(NewContextReader(ctx, rc))
+    for {
+        h, err := tr.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("read tar: %w", err)
+        }
+        e, ok := tarEntry(h)
+        if !ok {
+            continue
+        }
+        open := func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+        if e.IsSymlink() {
+            link := e.Linkname
+            open = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(link)), nil }
+        }
+        if err := fn(e, open); err != nil {
+            return err
+        }
+    }
+}
+
+func (a *tarArchive) compressedRead() int64 { return a.read.Load() }
+
+// meteredReader counts the bytes read through it.
+type meteredReader struct {
+    r io.Reader
+    n *atomic.Int64
+}
+
+func (m *meteredReader) Read(p []byte) (int, error) {
+    n, err := m.r.Read(p)
+    m.n.Add(int64(n))
+    return n, err
+}
+
+func (a *tarArchive) List() ([]Entry, error) {
+    a.once.Do(func() {
+        a.listErr = a.walk(context.Background(), func(e Entry, _ entryOpener) error {
+            a.entries = append(a.entries, e)
+            return nil
+        })
+    })
+    return a.entries, a.listErr
+}
+
+func (a *tarArchive) Open(name string) (io.ReadCloser, error) {
+    return openByWalk(a, name)
+}
+
+func (a *tarArchive) Extract(ctx context.Context, outDir string, opts ExtractOptions) (*ExtractSummary, error) {
+    return extractArchive(ctx, a, outDir, opts)
+}
+
+func (a *tarArchive) Close() error {
+    if a.closer != nil {
+        return a.closer.Close()
+    }
+    return nil
+}
@@ -0,0 +1,144 @@
+package streamline_core
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+type zipArchive struct {
+    zr       *zip.Reader
+    r        io.ReaderAt
+    closer   io.Closer
+    index    map[string]*zip.File
+    password string
+}
+
+func newZipArchive(r io.ReaderAt, size int64, closer io.Closer, opts ArchiveOptions) (*zipArchive, error) {
+    // archive/zip would misread the last disk of a split set as a whole
+    // archive whose entries then fail to open.
+    if end, _, err := readZipEOCD(r, size); err == nil && end.disk > 0 {
+        return nil, fmt.Errorf("%w: this is the last of %d disks", ErrSplitArchive, end.disk+1)
+    }
+    zr, err := zip.NewReader(r, size)
+    if err != nil {
+        return nil, fmt.Errorf("zip reader: %w", err)
+    }
+    index := make(map[string]*zip.File, len(zr.File))
+    for _, f := range zr.File {
+        if _, dup := index[f.Name]; !dup {
+            index[f.Name] = f
+        }
+    }
+    return &zipArchive{zr: zr, r: r, closer: closer, index: index, password: opts.Password}, nil
+}
+
+// zipEntry converts a zip.File header into a format-neutral Entry.
+func zipEntry(f *zip.File) Entry {
+    encrypted := isEncrypted(f)
+    return Entry{
+        Name:           f.Name,
+        Size:           int64(f.UncompressedSize64),
+        CompressedSize: int64(f.CompressedSize64),
+        Mode:           f.Mode(),
+        Modified:       f.Modified,
+        IsDir:          f.FileInfo().IsDir(),
+        CRC32:          f.CRC32,
+        HasCRC:         !encrypted || f.CRC32 != 0, // AE-2 stores no CRC
+        Encrypted:      encrypted,
+        Method:         zipMethodName(f),
+        Comment:        f.Comment,
+    }
+}
+
+var zipMethodNames = map[uint16]string{
+    zip.Store:   "store",
+    zip.Deflate: "deflate",
+    9:           "deflate64",
+    12:          "bzip2",
+    14:          "lzma",
+    93:          "zstd",
+    95:          "xz",
+    98:          "ppmd",
+}
+
+// zipMethodName names f's compression method, looking through WinZip AES
+// to the method underneath.
+func zipMethodName(f *zip.File) string {
+    method := f.Method
+    if method == zipMethodAES {
+        if info, ok := parseAESExtra(f.Extra); ok {
+            method = info.method
+        }
+    }
+    if name, ok := zipMethodNames[method]; ok {
+        return name
+    }
+    return fmt.Sprintf("method %d", method)
+}
+
+// open opens an entry, decrypting it when needed.
+func (a *zipArchive) open(f *zip.File) (io.ReadCloser, error) {
+    if isEncrypted(f) {
+        return openEncrypted(f, a.password)
+    }
+    return f.Open()
+}
+
+// openStored returns the raw bytes of an entry stored without compression
+// or encryption, which are its contents, or false for any other entry.
+func (a *zipArchive) openStored(name string) (*io.SectionReader, bool) {
+    f, ok := a.index[name]
+    if !ok || f.Method != zip.Store || isEncrypted(f) {
+        return nil, false
+    }
+    off, err := f.DataOffset()
+    if err != nil {
+        return nil, false
+    }
+    return io.NewSectionReader(a.r, off, int64(f.UncompressedSize64)), true
+}
+
+func (a *zipArchive) Format() Format     { return FormatZip }
+func (a *zipArchive) randomAccess() bool { return true }
+
+func (a *zipArchive) List() ([]Entry, error) {
+    entries := make([]Entry, 0, len(a.zr.File))
+    for _, f := range a.zr.File {
+        entries = append(entries, zipEntry(f))
+    }
+    return entries, nil
+}
+
+func (a *zipArchive) Open(name string) (io.ReadCloser, error) {
+    f, ok := a.index[name]
+    if !ok {
+        return nil, fmt.Errorf("open %s: %w", name, os.ErrNotExist)
+    }
+    return a.open(f)
+}
+
+func (a *zipArchive) walk(ctx context.Context, fn func(e Entry, open entryOpener) error) error {
+    for _, f := range a.zr.File {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if err := fn(zipEntry(f), func() (io.ReadCloser, error) { return a.open(f) }); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (a *zipArchive) Extract(ctx context.Context, outDir string, opts ExtractOptions) (*ExtractSummary, error) {
+    return extractArchive(ctx, a, outDir, opts)
+}
+
+func (a *zipArchive) Close() error {
+    if a.closer != nil {
+        return a.closer.Close()
+    }
+    return nil
+}
@@ -0,0 +1,181 @@
+package streamline_core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CheckpointFileName is the manifest written into the output directory.
+const CheckpointFileName = ".streamline-checkpoint.jsonl"
+
+// ErrAlreadyComplete is passed to ExtractOptions.OnEntry for entries
+// skipped because the Checkpoint has them as complete.
+var ErrAlreadyComplete = errors.New("skipped: already extracted by a previous run")
+
+// CheckpointEntry records one archive entry that was fully written to disk.
+type CheckpointEntry struct {
+    Name  string `json:"name"` // the mapped output path, slash-separated
+    CRC32 uint32 `json:"crc32"`
+    Size  uint64 `json:"size"`
+}
+
+type checkpointHeader struct {
+    ArchiveID string          `json:"archiveId"`
+    Paths     json.RawMessage `json:"paths,omitempty"` // the PathMapping, unless zero
+}
+
+// Checkpoint tracks completed entries of an extraction so that a re-run
+// into the same output directory can skip them. The manifest is an
+// append-only JSON-lines file: a header line naming the archive followed by
+// one line per completed entry. A torn last line from a crash is ignored.
+type Checkpoint struct {
+    outDir string
+
+    mu      sync.Mutex
+    f       *os.File
+    entries map[string]CheckpointEntry
+}
+
+// LoadCheckpoint opens (or creates) the checkpoint manifest in outDir for the
+// given archive. A manifest left behind by a different archive is discarded.
+func LoadCheckpoint(outDir, archiveID string) (*Checkpoint, error) {
+    return LoadCheckpointWithPaths(outDir, archiveID, PathMapping{})
+}
+
+// LoadCheckpointWithPaths is LoadCheckpoint for an extraction that maps
+// entry paths. Entries are recorded under their mapped paths, so a manifest
+// written with different mapping options is discarded too.
+func LoadCheckpointWithPaths(outDir, archiveID string, paths PathMapping) (*Checkpoint, error) {
+    var pathsKey json.RawMessage
+    if !paths.IsZero() {
+        b, err := json.Marshal(paths)
+        if err != nil {
+            return nil, fmt.Errorf("encode checkpoint: %w", err)
+        }
+        pathsKey = b
+    }
+    path := filepath.Join(outDir, CheckpointFileName)
+    entries := make(map[string]CheckpointEntry)
+
+    fresh := true
+    if in, err := os.Open(path); err == nil {
+        sc := bufio.NewScanner(in)
+        sc.Buffer(make([]byte, 64*1024), 1<<20)
+        if sc.Scan() {
+            var hdr checkpointHeader
+            if json.Unmarshal(sc.Bytes(), &hdr) == nil && hdr.ArchiveID == archiveID && bytes.Equal(hdr.Paths, pathsKey) {
+                fresh = false
+                for sc.Scan() {
+                    var e CheckpointEntry
+                    if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+                        continue
+                    }
+                    entries[e.Name] = e
+                }
+            }
+        }
+        in.Close()
+    } else if !os.IsNotExist(err) {
+        return nil, fmt.Errorf("open checkpoint: %w", err)
+    }
+
+    var (
+        f   *os.File
+        err error
+    )
+    if fresh {
+        f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+    } else {
+        f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("open checkpoint: %w", err)
+    }
+
+    c := &Checkpoint{outDir: outDir, f: f, entries: entries}
+    if fresh {
+        if err := c.appendLine(checkpointHeader{ArchiveID: archiveID, Paths: pathsKey}); err != nil {
+            f.Close()
+            return nil, err
+        }
+    } else {
+        // Start on a fresh line in case the previous run died mid-write.
+        if _, err := f.WriteString("\n"); err != nil {
+            f.Close()
+            return nil, fmt.Errorf("write checkpoint: %w", err)
+        }
+    }
+    return c, nil
+}
+
+// IsComplete reports whether the entry at name, its mapped output path, was
+// recorded as fully written by a previous run and the file on disk still
+// has the recorded size. A negative size, for entries whose size the
+// archive does not give, matches whatever size was recorded.
+func (c *Checkpoint) IsComplete(name string, crc uint32, size int64) bool {
+    c.mu.Lock()
+    e, ok := c.entries[name]
+    c.mu.Unlock()
+    if !ok || e.CRC32 != crc || (size >= 0 && e.Size != uint64(size)) {
+        return false
+    }
+    st, err := os.Stat(filepath.Join(c.outDir, name))
+    if err != nil {
+        return false
+    }
+    return st.IsDir() || uint64(st.Size()) == e.Size
+}
+
+// MarkComplete records that the entry has been fully written.
+func (c *Checkpoint) MarkComplete(name string, crc uint32, size uint64) error {
+    e := CheckpointEntry{Name: name, CRC32: crc, Size: size}
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.entries[name] = e
+    return c.appendLineLocked(e)
+}
+
+// Len returns the number of completed entries known to the checkpoint.
+func (c *Checkpoint) Len() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return len(c.entries)
+}
+
+// Close flushes and closes the manifest.
+func (c *Checkpoint) Close() error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.f == nil {
+        return nil
+    }
+    err := c.f.Close()
+    c.f = nil
+    return err
+}
+
+func (c *Checkpoint) appendLine(v interface{}) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.appendLineLocked(v)
+}
+
+func (c *Checkpoint) appendLineLocked(v interface{}) error {
+    if c.f == nil {
+        return fmt.Errorf("checkpoint closed")
+    }
+    b, err := json.Marshal(v)
+    if err != nil {
+        return fmt.Errorf("encode checkpoint: %w", err)
+    }
+    if _, err := c.f.Write(append(b, '\n')); err != nil {
+        return fmt.Errorf("write checkpoint: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,128 @@
+package streamline_core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ConflictPolicy decides what happens when an entry's target file already
+// exists. Directories are always merged.
+type ConflictPolicy string
+
+const (
+    ConflictOverwrite ConflictPolicy = "overwrite" // replace the existing file
+    ConflictSkip      ConflictPolicy = "skip"      // keep the existing file
+    ConflictRename    ConflictPolicy = "rename"    // write to "name_1.ext", "name_2.ext", ...
+    ConflictNewer     ConflictPolicy = "newer"     // replace only if the entry is newer
+    ConflictFail      ConflictPolicy = "fail"      // fail the entry
+)
+
+// ConflictPolicies lists the valid policies, in the order shown in help text.
+var ConflictPolicies = []ConflictPolicy{ConflictOverwrite, ConflictSkip, ConflictRename, ConflictNewer, ConflictFail}
+
+// ParseConflictPolicy validates a policy name. The empty string means
+// ConflictOverwrite, which was the only behaviour before policies existed.
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+    if s == "" {
+        return ConflictOverwrite, nil
+    }
+    for _, p := range ConflictPolicies {
+        if string(p) == strings.ToLower(s) {
+            return p, nil
+        }
+    }
+    return "", fmt.Errorf("unknown conflict policy %q (want one of %v)", s, ConflictPolicies)
+}
+
+// ErrTargetExists is the entry error under ConflictFail.
+var ErrTargetExists = errors.New("target already exists")
+
+// ErrSkippedExisting is passed to ExtractOptions.OnEntry for entries left
+// alone because of the conflict policy. They count as skipped, not failed.
+var ErrSkippedExisting = errors.New("skipped: target already exists")
+
+// ConflictAction is what was done about one conflict.
+type ConflictAction string
+
+const (
+    ActionOverwritten ConflictAction = "overwritten"
+    ActionSkipped     ConflictAction = "skipped"
+    ActionRenamed     ConflictAction = "renamed"
+    ActionFailed      ConflictAction = "failed"
+)
+
+// Conflict records the decision taken for an entry whose target existed.
+type Conflict struct {
+    Name      string // entry name
+    Target    string // the existing path
+    Policy    ConflictPolicy
+    Action    ConflictAction
+    RenamedTo string // set for ActionRenamed
+}
+
+// conflictResolver applies a ConflictPolicy across one extraction. Paths
+// handed out are reserved, so parallel workers never pick the same name.
+type conflictResolver struct {
+    policy ConflictPolicy
+
+    mu       sync.Mutex
+    reserved map[string]bool
+}
+
+func newConflictResolver(policy ConflictPolicy) *conflictResolver {
+    if policy == "" {
+        policy = ConflictOverwrite
+    }
+    return &conflictResolver{policy: policy, reserved: make(map[string]bool)}
+}
+
+// resolve returns the path e should be written to. A non-nil Conflict is
+// returned whenever the target already existed; err is ErrSkippedExisting
+// or wraps ErrTargetExists when the entry must not be written.
+func (r *conflictResolver) resolve(e Entry, targetPath string) (string, *Conflict, error) {
+    if e.IsDir {
+        return targetPath, nil, nil
+    }
+    r.mu.Lock()
+    defer r.mu.Unlock()
+
+    st, statErr := os.Lstat(targetPath)
+    if statErr != nil && !r.reserved[targetPath] {
+        r.reserved[targetPath] = true
+        return targetPath, nil, nil
+    }
+    c := &Conflict{Name: e.Name, Target: targetPath, Policy: r.policy}
+    switch r.policy {
+    case ConflictSkip:
+        c.Action = ActionSkipped
+        return targetPath, c, ErrSkippedExisting
+    case ConflictFail:
+        c.Action = ActionFailed
+        return targetPath, c, fmt.Errorf("%w: %s", ErrTargetExists, targetPath)
+    case ConflictNewer:
+        // Entries without a timestamp never replace anything.
+        if statErr == nil && !e.Modified.After(st.ModTime()) {
+            c.Action = ActionSkipped
+            return targetPath, c, ErrSkippedExisting
+        }
+        c.Action = ActionOverwritten
+        return targetPath, c, nil
+    case ConflictRename:
+        dir, base := filepath.Split(targetPath)
+        for i := 1; ; i++ {
+            candidate := filepath.Join(dir, suffixed(base, i))
+            if _, err := os.Lstat(candidate); err != nil && !r.reserved[candidate] {
+                r.reserved[candidate] = true
+                c.Action, c.RenamedTo = ActionRenamed, candidate
+                return candidate, c, nil
+            }
+        }
+    default:
+        c.Action = ActionOverwritten
+        return targetPath, c, nil
+    }
+}
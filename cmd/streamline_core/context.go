@@ -0,0 +1,24 @@
+package streamline_core
+
+import (
+	"context"
+	"io"
+)
+
+type contextReader struct {
+    ctx context.Context
+    r   io.Reader
+}
+
+// NewContextReader wraps r so that every Read first checks ctx and fails
+// with ctx.Err() once it is done.
+func NewContextReader(ctx context.Context, r io.Reader) io.Reader {
+    return &contextReader{ctx: ctx, r: r}
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+    if err := c.ctx.Err(); err != nil {
+        return 0, err
+    }
+    return c.r.Read(p)
+}
@@ -0,0 +1,82 @@
+package streamline_core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrIsDir is returned by OpenEntry for directory entries.
+var ErrIsDir = errors.New("entry is a directory")
+
+// OpenEntry opens one entry of the archive at zipPath for streaming, without
+// extracting anything. The decompressed stream is metered against the byte
+// and ratio limits. Closing the reader closes the archive.
+//
+// A ZIP entry stored without compression or encryption is returned as an
+// io.ReadSeeker as well, reading straight from the archive, so callers can
+// serve byte ranges of it. Its CRC32 is not checked on that path.
+func OpenEntry(zipPath, name string, limits Limits) (Entry, io.ReadCloser, error) {
+    a, err := OpenArchive(zipPath)
+    if err != nil {
+        return Entry{}, nil, err
+    }
+    e, err := findEntry(a, name)
+    if err == nil && e.IsDir {
+        err = fmt.Errorf("open %s: %w", name, ErrIsDir)
+    }
+    if err != nil {
+        a.Close()
+        return e, nil, err
+    }
+
+    if za, ok := a.(*zipArchive); ok {
+        if sr, ok := za.openStored(e.Name); ok {
+            return e, &storedEntryReader{SectionReader: sr, archive: a}, nil
+        }
+    }
+    rc, err := newLimitGuard(limits).opener(e, func() (io.ReadCloser, error) { return a.Open(e.Name) })()
+    if err != nil {
+        a.Close()
+        return e, nil, err
+    }
+    return e, &entryReader{ReadCloser: rc, archive: a}, nil
+}
+
+// findEntry looks name up in a's listing; the first entry of that name wins,
+// as it does for Archive.Open.
+func findEntry(a Archive, name string) (Entry, error) {
+    entries, err := a.List()
+    if err != nil {
+        return Entry{}, err
+    }
+    for _, e := range entries {
+        if e.Name == name {
+            return e, nil
+        }
+    }
+    return Entry{}, fmt.Errorf("open %s: %w", name, os.ErrNotExist)
+}
+
+// entryReader is an entry's stream that closes its archive with it.
+type entryReader struct {
+    io.ReadCloser
+    archive Archive
+}
+
+func (r *entryReader) Close() error {
+    err := r.ReadCloser.Close()
+    if cerr := r.archive.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+// storedEntryReader reads a stored entry in place within its archive.
+type storedEntryReader struct {
+    *io.SectionReader
+    archive Archive
+}
+
+func (r *storedEntryReader) Close() error { return r.archive.Close() }
@@ -0,0 +1,238 @@
+package streamline_core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// DefaultFilterFile is the conventional name of a filter file.
+const DefaultFilterFile = ".streamlineignore"
+
+// Filter selects archive entries by path, size and modification time. The
+// zero Filter selects everything.
+//
+// Patterns use doublestar syntax ("docs/**/*.md") against the full entry
+// path. As in .gitignore, a pattern without a slash matches a name at any
+// depth, a leading slash anchors it to the archive root, and a pattern that
+// matches a directory also matches everything beneath it.
+type Filter struct {
+    // Include, when non-empty, keeps only entries matching one pattern.
+    Include []string
+    // Exclude drops entries matching a pattern; "!pattern" brings matches
+    // back. The last matching pattern wins.
+    Exclude []string
+    // Size and time bounds apply to files only. Zero values are unbounded.
+    MinSize int64
+    MaxSize int64
+    After   time.Time // modified at or after
+    Before  time.Time // modified strictly before
+}
+
+// Match reports whether e passes the filter.
+func (f *Filter) Match(e Entry) bool {
+    if f == nil {
+        return true
+    }
+    if len(f.Include) > 0 {
+        included := false
+        for _, p := range f.Include {
+            if MatchPath(p, e.Name) {
+                included = true
+                break
+            }
+        }
+        if !included {
+            return false
+        }
+    }
+    excluded := false
+    for _, p := range f.Exclude {
+        if neg := strings.HasPrefix(p, "!"); neg && MatchPath(p[1:], e.Name) {
+            excluded = false
+        } else if !neg && MatchPath(p, e.Name) {
+            excluded = true
+        }
+    }
+    if excluded || e.IsDir {
+        return !excluded
+    }
+    switch {
+    case f.MinSize > 0 && e.Size >= 0 && e.Size < f.MinSize:
+        return false
+    case f.MaxSize > 0 && e.Size > f.MaxSize:
+        return false
+    case !f.After.IsZero() && e.Modified.Before(f.After):
+        return false
+    case !f.Before.IsZero() && !e.Modified.Before(f.Before):
+        return false
+    }
+    return true
+}
+
+// Merge adds other's rules to f. Bounds from other replace f's where set.
+func (f *Filter) Merge(other *Filter) {
+    if other == nil {
+        return
+    }
+    f.Include = append(f.Include, other.Include...)
+    f.Exclude = append(f.Exclude, other.Exclude...)
+    if other.MinSize > 0 {
+        f.MinSize = other.MinSize
+    }
+    if other.MaxSize > 0 {
+        f.MaxSize = other.MaxSize
+    }
+    if !other.After.IsZero() {
+        f.After = other.After
+    }
+    if !other.Before.IsZero() {
+        f.Before = other.Before
+    }
+}
+
+// MatchPath reports whether a Filter pattern matches the slash-separated
+// entry name or one of its parent directories.
+func MatchPath(pattern, name string) bool {
+    anchored := strings.HasPrefix(pattern, "/")
+    pattern = strings.Trim(pattern, "/")
+    name = strings.Trim(name, "/")
+    if pattern == "" || name == "" {
+        return false
+    }
+    parts := strings.Split(name, "/")
+    perName := !anchored && !strings.Contains(pattern, "/")
+    for i := range parts {
+        candidate := parts[i]
+        if !perName {
+            candidate = strings.Join(parts[:i+1], "/")
+        }
+        if ok, _ := doublestar.Match(pattern, candidate); ok {
+            return true
+        }
+    }
+    return false
+}
+
+// ResolveFilterFile returns path, or DefaultFilterFile when path is empty
+// and one exists in the working directory, or "" when there is none.
+func ResolveFilterFile(path string) string {
+    if path != "" {
+        return path
+    }
+    if _, err := os.Stat(DefaultFilterFile); err == nil {
+        return DefaultFilterFile
+    }
+    return ""
+}
+
+// LoadFilterFile reads a filter file; see ParseFilter for the syntax.
+func LoadFilterFile(path string) (*Filter, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+    filter, err := ParseFilter(f)
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return filter, nil
+}
+
+// ParseFilter reads filter rules, one per line, in .gitignore style:
+//
+//	# comment
+//	node_modules          exclude (at any depth)
+//	!node_modules/keep.js re-include
+//	+docs/**/*.md         include; once any is given, nothing else is
+//	@min-size 1KB         size bounds take B, KB, MB, GB, KiB, MiB, GiB
+//	@max-size 2GiB
+//	@after 2024-01-01     modification time bounds, date or RFC 3339
+//	@before 2025-01-01T00:00:00Z
+//
+// A backslash escapes a leading '#', '!', '+' or '@'.
+func ParseFilter(r io.Reader) (*Filter, error) {
+    f := &Filter{}
+    sc := bufio.NewScanner(r)
+    for n := 1; sc.Scan(); n++ {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || line[0] == '#' {
+            continue
+        }
+        var err error
+        switch line[0] {
+        case '\\':
+            // Kept as is: doublestar reads the backslash as an escape too.
+            f.Exclude = append(f.Exclude, line)
+        case '+':
+            f.Include = append(f.Include, strings.TrimSpace(line[1:]))
+        case '@':
+            key, value, _ := strings.Cut(line[1:], " ")
+            value = strings.TrimSpace(value)
+            switch key {
+            case "min-size":
+                f.MinSize, err = ParseSize(value)
+            case "max-size":
+                f.MaxSize, err = ParseSize(value)
+            case "after":
+                f.After, err = ParseDate(value)
+            case "before":
+                f.Before, err = ParseDate(value)
+            default:
+                err = fmt.Errorf("unknown directive @%s", key)
+            }
+        default:
+            f.Exclude = append(f.Exclude, line)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %w", n, err)
+        }
+    }
+    return f, sc.Err()
+}
+
+var sizeUnits = []struct {
+    suffix string
+    mult   int64
+}{
+    {"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+    {"KB", 1000}, {"MB", 1000 * 1000}, {"GB", 1000 * 1000 * 1000}, {"TB", 1000 * 1000 * 1000 * 1000},
+    {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+    {"B", 1},
+}
+
+// ParseSize parses a byte count such as "512", "10MB" or "1.5GiB".
+func ParseSize(s string) (int64, error) {
+    upper := strings.ToUpper(strings.TrimSpace(s))
+    mult := int64(1)
+    for _, u := range sizeUnits {
+        if strings.HasSuffix(upper, u.suffix) {
+            upper, mult = strings.TrimSpace(strings.TrimSuffix(upper, u.suffix)), u.mult
+            break
+        }
+    }
+    v, err := strconv.ParseFloat(upper, 64)
+    if err != nil || v < 0 {
+        return 0, fmt.Errorf("invalid size %q", s)
+    }
+    return int64(v * float64(mult)), nil
+}
+
+// ParseDate parses a date ("2006-01-02", local time) or an RFC 3339 time.
+func ParseDate(s string) (time.Time, error) {
+    if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+        return t, nil
+    }
+    t, err := time.Parse(time.RFC3339, s)
+    if err != nil {
+        return time.Time{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD or RFC 3339)", s)
+    }
+    return t, nil
+}
@@ -0,0 +1,202 @@
+package streamline_core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// Limits bounds what an extraction may consume. Zero fields are unlimited.
+// Sizes are counted from the decompressed bytes actually written, never
+// from archive headers, which a hostile archive can falsify.
+type Limits struct {
+    MaxTotalBytes int64   // decompressed bytes across all entries
+    MaxEntryBytes int64   // decompressed bytes of any single entry
+    MaxEntries    int     // files extracted; directories are not counted
+    MaxRatio      float64 // decompressed:compressed bytes of an entry (of the whole stream for .tar.gz etc.)
+    MaxDepth      int     // directory nesting depth of entry paths
+}
+
+// DefaultLimits stops classic decompression bombs without getting in the
+// way of large legitimate archives.
+var DefaultLimits = Limits{
+    MaxEntries: 1 << 20,
+    MaxRatio:   1000,
+    MaxDepth:   64,
+}
+
+// ratioGraceBytes is how much an entry may expand before MaxRatio applies,
+// so tiny but highly compressible files are not flagged.
+const ratioGraceBytes = 1 << 20
+
+// LimitKind names the limit a LimitError refers to. The values double as
+// stable error codes for JSON reports.
+type LimitKind string
+
+const (
+    LimitTotalBytes LimitKind = "total_bytes_exceeded"
+    LimitEntryBytes LimitKind = "entry_bytes_exceeded"
+    LimitEntries    LimitKind = "entry_count_exceeded"
+    LimitRatio      LimitKind = "compression_ratio_exceeded"
+    LimitDepth      LimitKind = "nesting_depth_exceeded"
+)
+
+// ErrLimitExceeded matches every LimitError with errors.Is.
+var ErrLimitExceeded = errors.New("extraction limit exceeded")
+
+// LimitError reports that an extraction crossed one of its Limits.
+type LimitError struct {
+    Kind LimitKind
+    Max  string // the configured limit, formatted for humans
+
+    stream bool // the ratio of a whole compressed stream, not one entry's
+}
+
+func (e *LimitError) Error() string {
+    return fmt.Sprintf("%s: %s (limit %s)", ErrLimitExceeded, strings.ReplaceAll(string(e.Kind), "_", " "), e.Max)
+}
+
+func (e *LimitError) Is(target error) bool { return target == ErrLimitExceeded }
+
+// fatal reports whether the limit applies to the whole extraction, so that
+// carrying on with other entries is pointless.
+func (e *LimitError) fatal() bool {
+    return e.Kind == LimitTotalBytes || e.Kind == LimitEntries || e.stream
+}
+
+// ErrorCode returns a stable machine-readable code for errors that have
+// one, or "" otherwise.
+func ErrorCode(err error) string {
+    var lerr *LimitError
+    switch {
+    case errors.As(err, &lerr):
+        return string(lerr.Kind)
+    case errors.Is(err, ErrPasswordRequired):
+        return "password_required"
+    case errors.Is(err, ErrBadPassword):
+        return "bad_password"
+    case errors.Is(err, ErrTargetExists):
+        return "target_exists"
+    case errors.Is(err, ErrVerifyFailed):
+        return "verify_failed"
+    }
+    return ""
+}
+
+// limitGuard enforces Limits across one extraction.
+type limitGuard struct {
+    limits  Limits
+    total   atomic.Int64
+    entries atomic.Int64
+    stream  streamMeter // set when entries share one compressed stream
+}
+
+func newLimitGuard(l Limits) *limitGuard {
+    return &limitGuard{limits: l}
+}
+
+// streamMeter is implemented by archives whose entries are decompressed
+// from one shared stream, like .tar.gz. Their headers cannot say how much
+// of it each entry took, so MaxRatio is applied to the running totals.
+type streamMeter interface {
+    // compressedRead returns the compressed bytes consumed so far.
+    compressedRead() int64
+}
+
+// meter makes g check the stream ratio of src if it has one.
+func (g *limitGuard) meter(src any) *limitGuard {
+    if m, ok := src.(streamMeter); ok {
+        g.stream = m
+    }
+    return g
+}
+
+// streamRatioExceeded reports whether total decompressed bytes are too
+// many for the compressed bytes read to produce them.
+func (g *limitGuard) streamRatioExceeded(total int64) bool {
+    if g.stream == nil || g.limits.MaxRatio <= 0 || total <= ratioGraceBytes {
+        return false
+    }
+    in := g.stream.compressedRead()
+    return in > 0 && float64(total) > float64(in)*g.limits.MaxRatio
+}
+
+// admit checks the per-entry limits that can be decided before any data is
+// read and counts the entry.
+func (g *limitGuard) admit(e Entry) error {
+    if max := g.limits.MaxDepth; max > 0 && entryDepth(e.Name) > max {
+        return &LimitError{Kind: LimitDepth, Max: fmt.Sprint(max)}
+    }
+    if e.IsDir {
+        return nil
+    }
+    if max := g.limits.MaxEntries; max > 0 && g.entries.Add(1) > int64(max) {
+        return &LimitError{Kind: LimitEntries, Max: fmt.Sprint(max)}
+    }
+    return nil
+}
+
+// opener wraps open so the entry's decompressed stream is metered against
+// the byte and ratio limits.
+func (g *limitGuard) opener(e Entry, open entryOpener) entryOpener {
+    return func() (io.ReadCloser, error) {
+        rc, err := open()
+        if err != nil {
+            return nil, err
+        }
+        return &limitedReader{ReadCloser: rc, g: g, compressed: e.CompressedSize}, nil
+    }
+}
+
+// entryDepth counts the directories an entry path is nested in.
+func entryDepth(name string) int {
+    name = strings.Trim(name, "/")
+    if name == "" {
+        return 0
+    }
+    return strings.Count(name, "/")
+}
+
+type limitedReader struct {
+    io.ReadCloser
+    g          *limitGuard
+    compressed int64
+    n          int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+    n, err := r.ReadCloser.Read(p)
+    if n == 0 {
+        return n, err
+    }
+    r.n += int64(n)
+    l := r.g.limits
+    total := r.g.total.Add(int64(n))
+    switch {
+    case l.MaxEntryBytes > 0 && r.n > l.MaxEntryBytes:
+        return 0, &LimitError{Kind: LimitEntryBytes, Max: formatBytes(l.MaxEntryBytes)}
+    case l.MaxTotalBytes > 0 && total > l.MaxTotalBytes:
+        return 0, &LimitError{Kind: LimitTotalBytes, Max: formatBytes(l.MaxTotalBytes)}
+    case l.MaxRatio > 0 && r.compressed > 0 && r.n > ratioGraceBytes &&
+        float64(r.n) > float64(r.compressed)*l.MaxRatio:
+        return 0, &LimitError{Kind: LimitRatio, Max: fmt.Sprintf("%g:1", l.MaxRatio)}
+    case r.g.streamRatioExceeded(total):
+        return 0, &LimitError{Kind: LimitRatio, Max: fmt.Sprintf("%g:1", l.MaxRatio), stream: true}
+    }
+    return n, err
+}
+
+func formatBytes(n int64) string {
+    const unit = 1024
+    if n < unit {
+        return fmt.Sprintf("%d B", n)
+    }
+    div, exp := int64(unit), 0
+    for m := n / unit; m >= unit; m /= unit {
+        div *= unit
+        exp++
+    }
+    return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,206 @@
+package streamline_core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxLinkTarget bounds how much of a ZIP symlink entry is read as its target.
+const maxLinkTarget = 4096
+
+// ErrUnsafeSymlink is returned for symlinks that would resolve outside the
+// output directory, and for entries that would be written through one.
+var ErrUnsafeSymlink = errors.New("symlink escapes output directory")
+
+// IsSymlink reports whether the entry is a symbolic link.
+func (e Entry) IsSymlink() bool {
+    return e.Mode&os.ModeSymlink != 0
+}
+
+// preserver applies the file metadata that ExtractOptions asks to keep.
+// Directory metadata is applied last, so a read-only directory (or one
+// whose mtime would be bumped by its children) is only finalised once
+// everything inside it has been written.
+type preserver struct {
+    modes, times, symlinks bool
+    outDir                 string
+
+    mu   sync.Mutex
+    dirs map[string]Entry
+}
+
+func newPreserver(outDir string, opts ExtractOptions) *preserver {
+    return &preserver{
+        modes:    opts.PreserveModes,
+        times:    opts.PreserveTimes,
+        symlinks: opts.PreserveSymlinks,
+        outDir:   outDir,
+        dirs:     make(map[string]Entry),
+    }
+}
+
+// checkParent refuses to write through a symlinked directory that resolves
+// outside outDir, and removes a symlink already at targetPath so that it is
+// replaced rather than followed. Only needed once symlinks can exist in the
+// output.
+func (p *preserver) checkParent(targetPath string) error {
+    if !p.symlinks {
+        return nil
+    }
+    if st, err := os.Lstat(targetPath); err == nil && st.Mode()&os.ModeSymlink != 0 {
+        if err := os.Remove(targetPath); err != nil {
+            return fmt.Errorf("replace symlink: %w", err)
+        }
+    }
+    dir := filepath.Dir(targetPath)
+    for {
+        if _, err := os.Lstat(dir); err == nil {
+            break
+        }
+        parent := filepath.Dir(dir)
+        if parent == dir {
+            return nil
+        }
+        dir = parent
+    }
+    if !p.resolvesWithin(dir) {
+        return fmt.Errorf("%w: %s", ErrUnsafeSymlink, targetPath)
+    }
+    return nil
+}
+
+// resolvesWithin reports whether path, with symlinks resolved, is outDir
+// or lies beneath it.
+func (p *preserver) resolvesWithin(path string) bool {
+    base, err := filepath.EvalSymlinks(p.outDir)
+    if err != nil {
+        return false
+    }
+    real, err := filepath.EvalSymlinks(path)
+    if err != nil {
+        return false
+    }
+    return isSameOrWithin(base, real)
+}
+
+func isSameOrWithin(base, path string) bool {
+    absBase, err1 := filepath.Abs(base)
+    absPath, err2 := filepath.Abs(path)
+    return err1 == nil && err2 == nil && (absBase == absPath || IsPathWithinBase(absBase, absPath))
+}
+
+// symlink creates e at targetPath. The target must be relative and stay
+// inside outDir both lexically and, where it already exists, once
+// resolved, so chains such as "a -> ." plus "b -> a/.." are caught.
+func (p *preserver) symlink(ctx context.Context, e Entry, open entryOpener, targetPath string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    target := e.Linkname
+    if target == "" {
+        // ZIP stores the link target as the entry's contents.
+        rc, err := open()
+        if err != nil {
+            return fmt.Errorf("open entry: %w", err)
+        }
+        b, err := io.ReadAll(io.LimitReader(rc, maxLinkTarget+1))
+        rc.Close()
+        if err != nil {
+            return fmt.Errorf("read link target: %w", err)
+        }
+        if len(b) > maxLinkTarget {
+            return fmt.Errorf("link target too long")
+        }
+        target = string(b)
+    }
+    if target == "" || filepath.IsAbs(target) || strings.HasPrefix(target, "/") || filepath.VolumeName(target) != "" {
+        return fmt.Errorf("%w: %s -> %s", ErrUnsafeSymlink, e.Name, target)
+    }
+    dir := filepath.Dir(targetPath)
+    lexical := filepath.Join(dir, filepath.FromSlash(target))
+    if !isSameOrWithin(p.outDir, lexical) {
+        return fmt.Errorf("%w: %s -> %s", ErrUnsafeSymlink, e.Name, target)
+    }
+    if containsDotDot(target) {
+        // Cleaning ".." lexically is only sound when nothing before it is a
+        // symlink; resolve it for real, and refuse if that is not possible.
+        raw := dir + string(os.PathSeparator) + filepath.FromSlash(target)
+        if !p.resolvesWithin(raw) {
+            return fmt.Errorf("%w: %s -> %s", ErrUnsafeSymlink, e.Name, target)
+        }
+    }
+
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return fmt.Errorf("mkdir parents: %w", err)
+    }
+    if st, err := os.Lstat(targetPath); err == nil && !st.IsDir() {
+        os.Remove(targetPath)
+    }
+    if err := os.Symlink(filepath.FromSlash(target), targetPath); err != nil {
+        return fmt.Errorf("create symlink: %w", err)
+    }
+    return nil
+}
+
+func containsDotDot(target string) bool {
+    for _, part := range strings.Split(filepath.ToSlash(target), "/") {
+        if part == ".." {
+            return true
+        }
+    }
+    return false
+}
+
+// apply sets the mode and mtime of a written file, or queues them for a
+// directory.
+func (p *preserver) apply(e Entry, targetPath string) error {
+    if !p.modes && !p.times {
+        return nil
+    }
+    if e.IsDir {
+        p.mu.Lock()
+        p.dirs[targetPath] = e
+        p.mu.Unlock()
+        return nil
+    }
+    return p.set(e, targetPath)
+}
+
+func (p *preserver) set(e Entry, targetPath string) error {
+    if p.modes && e.Mode.Perm() != 0 {
+        if err := os.Chmod(targetPath, e.Mode.Perm()); err != nil {
+            return fmt.Errorf("set mode: %w", err)
+        }
+    }
+    if p.times && !e.Modified.IsZero() {
+        if err := os.Chtimes(targetPath, e.Modified, e.Modified); err != nil {
+            return fmt.Errorf("set mtime: %w", err)
+        }
+    }
+    return nil
+}
+
+// finish applies queued directory metadata, deepest directories first.
+func (p *preserver) finish() error {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    paths := make([]string, 0, len(p.dirs))
+    for path := range p.dirs {
+        paths = append(paths, path)
+    }
+    sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+    var first error
+    for _, path := range paths {
+        if err := p.set(p.dirs[path], path); err != nil && first == nil {
+            first = &EntryError{Name: p.dirs[path].Name, Err: err}
+        }
+    }
+    return first
+}
@@ -0,0 +1,312 @@
+package streamline_core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+)
+
+// PackFormats are the formats NewArchiveWriter can produce.
+var PackFormats = []Format{FormatZip, FormatTarGz}
+
+// Compression levels for NewArchiveWriter, as in compress/flate.
+const (
+    DefaultCompression = flate.DefaultCompression
+    NoCompression      = flate.NoCompression
+    BestSpeed          = flate.BestSpeed
+    BestCompression    = flate.BestCompression
+)
+
+// ParsePackFormat validates an output format name; "tgz" is accepted for
+// FormatTarGz and the empty string means FormatZip.
+func ParsePackFormat(s string) (Format, error) {
+    switch strings.ToLower(s) {
+    case "", "zip":
+        return FormatZip, nil
+    case "tar.gz", "tgz":
+        return FormatTarGz, nil
+    }
+    return "", fmt.Errorf("unknown output format %q (want one of %v)", s, PackFormats)
+}
+
+// ArchiveWriter builds a new archive one entry at a time. It never seeks,
+// so it can write straight to a network stream.
+type ArchiveWriter struct {
+    format Format
+    level  int
+    zw     *zip.Writer
+    tw     *tar.Writer
+    gz     *gzip.Writer
+}
+
+// NewArchiveWriter starts an archive of format on w. level is one of the
+// compression levels above, or 1-9 in between; NoCompression stores ZIP
+// entries rather than deflating them.
+func NewArchiveWriter(w io.Writer, format Format, level int) (*ArchiveWriter, error) {
+    if level < DefaultCompression || level > BestCompression {
+        return nil, fmt.Errorf("invalid compression level %d", level)
+    }
+    aw := &ArchiveWriter{format: format, level: level}
+    switch format {
+    case FormatZip:
+        aw.zw = zip.NewWriter(w)
+        aw.zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+            return flate.NewWriter(out, level)
+        })
+    case FormatTarGz:
+        gz, err := gzip.NewWriterLevel(w, level)
+        if err != nil {
+            return nil, err
+        }
+        aw.gz, aw.tw = gz, tar.NewWriter(gz)
+    default:
+        return nil, fmt.Errorf("%w: cannot write %s", ErrUnsupportedFormat, format)
+    }
+    return aw, nil
+}
+
+// Format reports the format being written.
+func (aw *ArchiveWriter) Format() Format { return aw.format }
+
+// Add writes e under name, taking its contents from r. Directories need no
+// r; a symlink's r yields its target, as Archive.Open does. Tarballs record
+// sizes up front, so an entry of unknown size cannot be added to one.
+func (aw *ArchiveWriter) Add(name string, e Entry, r io.Reader) error {
+    name = strings.TrimSuffix(name, "/")
+    if !fs.ValidPath(name) || name == "." {
+        return fmt.Errorf("illegal path: %s", name)
+    }
+    mode := e.Mode
+    if mode.Perm() == 0 {
+        mode |= 0o644
+        if e.IsDir {
+            mode |= 0o111
+        }
+    }
+    modified := e.Modified
+    if modified.IsZero() {
+        modified = time.Now()
+    }
+
+    if aw.zw != nil {
+        fh := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified, Comment: e.Comment}
+        if e.IsDir {
+            fh.Name += "/"
+            fh.Method = zip.Store
+        } else if aw.level == NoCompression {
+            fh.Method = zip.Store
+        }
+        fh.SetMode(mode)
+        w, err := aw.zw.CreateHeader(fh)
+        if err != nil || e.IsDir {
+            return err
+        }
+        if e.IsSymlink() && e.Linkname != "" {
+            r = strings.NewReader(e.Linkname)
+        }
+        _, err = io.Copy(w, r)
+        return err
+    }
+
+    hdr := &tar.Header{Name: name, Mode: int64(mode.Perm()), ModTime: modified, Typeflag: tar.TypeReg}
+    switch {
+    case e.IsDir:
+        hdr.Name += "/"
+        hdr.Typeflag = tar.TypeDir
+    case e.IsSymlink():
+        hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.Linkname
+        if hdr.Linkname == "" {
+            target, err := io.ReadAll(io.LimitReader(r, 4096))
+            if err != nil {
+                return err
+            }
+            hdr.Linkname = string(target)
+        }
+    default:
+        if e.Size < 0 {
+            return fmt.Errorf("%s: size unknown; a tarball needs it up front", name)
+        }
+        hdr.Size = e.Size
+    }
+    if err := aw.tw.WriteHeader(hdr); err != nil {
+        return err
+    }
+    if hdr.Typeflag != tar.TypeReg {
+        return nil
+    }
+    _, err := io.Copy(aw.tw, r)
+    return err
+}
+
+// Close finishes the archive. It does not close the underlying writer.
+func (aw *ArchiveWriter) Close() error {
+    if aw.zw != nil {
+        return aw.zw.Close()
+    }
+    if err := aw.tw.Close(); err != nil {
+        return err
+    }
+    return aw.gz.Close()
+}
+
+// EntryWriter receives the entries Pack copies: an ArchiveWriter, or a
+// destination that stores each entry as it arrives.
+type EntryWriter interface {
+    // Add stores e under name as ArchiveWriter.Add does.
+    Add(name string, e Entry, r io.Reader) error
+}
+
+// PackSource supplies the entries Pack writes: an archive being repacked,
+// a directory tree, or a remote folder.
+type PackSource interface {
+    // Walk calls fn for each entry in order; open returns its contents and
+    // is only valid during the call.
+    Walk(ctx context.Context, fn func(e Entry, open func() (io.ReadCloser, error)) error) error
+}
+
+// Repack copies the entries of a into aw without touching disk; see Pack.
+func Repack(ctx context.Context, a Archive, aw EntryWriter, opts ExtractOptions) (*ExtractSummary, error) {
+    w, ok := a.(walker)
+    if !ok {
+        return nil, fmt.Errorf("repack: %T cannot be walked", a)
+    }
+    return Pack(ctx, walkerSource{w}, aw, opts)
+}
+
+// walkerSource adapts an archive's walk to PackSource.
+type walkerSource struct{ w walker }
+
+func (s walkerSource) compressedRead() int64 {
+    if m, ok := s.w.(streamMeter); ok {
+        return m.compressedRead()
+    }
+    return 0
+}
+
+func (s walkerSource) Walk(ctx context.Context, fn func(e Entry, open func() (io.ReadCloser, error)) error) error {
+    return s.w.walk(ctx, func(e Entry, open entryOpener) error { return fn(e, open) })
+}
+
+// Pack copies the entries of src that opts.Select picks into aw, named as
+// opts.Paths maps them. opts.Limits meter the bytes read and opts.OnEntry
+// sees each entry with its new name; the other options do not apply. An
+// entry that cannot be opened is skipped under ContinueOnError, but a
+// failure once its bytes are flowing leaves an ArchiveWriter unusable and
+// always ends the copy. The caller closes aw.
+func Pack(ctx context.Context, src PackSource, aw EntryWriter, opts ExtractOptions) (*ExtractSummary, error) {
+    summary := &ExtractSummary{}
+    guard := newLimitGuard(opts.Limits).meter(src)
+    mapper := newPathMapper(opts.Paths)
+    err := src.Walk(ctx, func(e Entry, open func() (io.ReadCloser, error)) error {
+        summary.Total++
+        name, ok := "", opts.Select == nil || opts.Select(e)
+        if ok {
+            name, ok = mapper.mapName(e)
+        }
+        if !ok {
+            summary.Skipped++
+            return nil
+        }
+
+        var rc io.ReadCloser
+        err := guard.admit(e)
+        if err == nil && !e.IsDir {
+            rc, err = guard.opener(e, open)()
+        }
+        if err != nil {
+            if opts.OnEntry != nil {
+                opts.OnEntry(e, name, err)
+            }
+            summary.Errors = append(summary.Errors, &EntryError{Name: e.Name, Err: err})
+            var lerr *LimitError
+            if !opts.ContinueOnError || (errors.As(err, &lerr) && lerr.fatal()) {
+                return &EntryError{Name: e.Name, Err: err}
+            }
+            return nil
+        }
+
+        var r io.Reader
+        if rc != nil {
+            defer rc.Close()
+            r = NewContextReader(ctx, rc)
+        }
+        err = aw.Add(name, e, r)
+        if opts.OnEntry != nil {
+            opts.OnEntry(e, name, err)
+        }
+        if err != nil {
+            summary.Errors = append(summary.Errors, &EntryError{Name: e.Name, Err: err})
+            return &EntryError{Name: e.Name, Err: err}
+        }
+        summary.Extracted++
+        return ctx.Err()
+    })
+    return summary, err
+}
+
+// DirSource walks the tree under dir in lexical order. Symlinks are packed
+// as links, never followed; sockets, devices and the like are left out.
+type DirSource struct {
+    Dir string
+}
+
+func (d DirSource) Walk(ctx context.Context, fn func(e Entry, open func() (io.ReadCloser, error)) error) error {
+    return filepath.WalkDir(d.Dir, func(p string, de fs.DirEntry, err error) error {
+        if err != nil {
+            return err
+        }
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        rel, err := filepath.Rel(d.Dir, p)
+        if err != nil || rel == "." {
+            return err
+        }
+        info, err := de.Info()
+        if err != nil {
+            return err
+        }
+        e := Entry{Name: filepath.ToSlash(rel), Mode: info.Mode(), Modified: info.ModTime()}
+        open := func() (io.ReadCloser, error) { return os.Open(p) }
+        switch {
+        case de.IsDir():
+            e.Name += "/"
+            e.IsDir = true
+            open = nil
+        case info.Mode()&fs.ModeSymlink != 0:
+            target, err := os.Readlink(p)
+            if err != nil {
+                return err
+            }
+            e.Linkname = filepath.ToSlash(target)
+            e.Size = int64(len(e.Linkname))
+            open = func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(e.Linkname)), nil }
+        case info.Mode().IsRegular():
+            e.Size = info.Size()
+        default:
+            return nil
+        }
+        return fn(e, open)
+    })
+}
+
+// PackFormatForName picks the output format a file name implies: a tarball
+// for .tar.gz and .tgz, otherwise FormatZip.
+func PackFormatForName(name string) Format {
+    lower := strings.ToLower(name)
+    if strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") {
+        return FormatTarGz
+    }
+    return FormatZip
+}
@@ -0,0 +1,108 @@
+package streamline_core
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PathRewrite replaces a leading directory of entry paths, e.g. "src" with
+// "lib". An empty To drops the prefix.
+type PathRewrite struct {
+    From string
+    To   string
+}
+
+// ParsePathRewrite parses "from=to" (or "from->to").
+func ParsePathRewrite(s string) (PathRewrite, error) {
+    from, to, ok := strings.Cut(s, "->")
+    if !ok {
+        from, to, ok = strings.Cut(s, "=")
+    }
+    from, to = strings.Trim(strings.TrimSpace(from), "/"), strings.Trim(strings.TrimSpace(to), "/")
+    if !ok || from == "" {
+        return PathRewrite{}, fmt.Errorf("invalid rewrite %q (want from=to)", s)
+    }
+    return PathRewrite{From: from, To: to}, nil
+}
+
+// PathMapping changes where entries land under the output directory. The
+// steps run in field order. The mapped path is what the traversal check
+// sees, so a rewrite cannot be used to escape the output directory.
+type PathMapping struct {
+    // StripComponents removes this many leading directories, as tar's
+    // --strip-components does. Entries with no path left are skipped.
+    StripComponents int
+    // Rewrites replace a leading directory; the first that matches wins.
+    Rewrites []PathRewrite
+    // Flatten writes every file directly into the output directory and
+    // skips directories. Files that would collide get "_1", "_2", ...
+    // suffixes in archive order.
+    Flatten bool
+}
+
+// IsZero reports whether m leaves paths unchanged.
+func (m PathMapping) IsZero() bool {
+    return m.StripComponents == 0 && len(m.Rewrites) == 0 && !m.Flatten
+}
+
+// pathMapper applies a PathMapping across one extraction. It must be used
+// in archive order from a single goroutine so flatten suffixes are stable.
+type pathMapper struct {
+    m    PathMapping
+    used map[string]bool
+}
+
+func newPathMapper(m PathMapping) *pathMapper {
+    return &pathMapper{m: m, used: make(map[string]bool)}
+}
+
+// mapName returns the slash-separated output path for e, with a trailing
+// slash for directories, or false if e should be skipped.
+func (p *pathMapper) mapName(e Entry) (string, bool) {
+    if p.m.IsZero() {
+        return e.Name, true
+    }
+    name := strings.Trim(e.Name, "/")
+    if n := p.m.StripComponents; n > 0 {
+        parts := strings.SplitN(name, "/", n+1)
+        if len(parts) <= n {
+            return "", false
+        }
+        name = parts[n]
+    }
+    for _, r := range p.m.Rewrites {
+        if name == r.From || strings.HasPrefix(name, r.From+"/") {
+            name = strings.Trim(r.To+name[len(r.From):], "/")
+            break
+        }
+    }
+    if p.m.Flatten {
+        if e.IsDir {
+            return "", false
+        }
+        name = path.Base(name)
+        base, n := name, 1
+        for p.used[name] {
+            name = suffixed(base, n)
+            n++
+        }
+        p.used[name] = true
+    }
+    if name == "" {
+        return "", false
+    }
+    if e.IsDir {
+        name += "/"
+    }
+    return name, true
+}
+
+// suffixed inserts "_n" before the extension of name.
+func suffixed(name string, n int) string {
+    ext := path.Ext(name)
+    if ext == name {
+        ext = "" // a dotfile such as ".env" has no extension to keep
+    }
+    return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(name, ext), n, ext)
+}
@@ -0,0 +1,439 @@
+package streamline_core
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MultiReaderAt presents ordered parts as one contiguous io.ReaderAt.
+type MultiReaderAt struct {
+    parts  []*io.SectionReader
+    starts []int64 // offset of each part within the whole
+    size   int64
+}
+
+func NewMultiReaderAt(parts ...*io.SectionReader) *MultiReaderAt {
+    m := &MultiReaderAt{parts: parts, starts: make([]int64, len(parts))}
+    for i, p := range parts {
+        m.starts[i] = m.size
+        m.size += p.Size()
+    }
+    return m
+}
+
+func (m *MultiReaderAt) Size() int64 { return m.size }
+
+func (m *MultiReaderAt) ReadAt(p []byte, off int64) (int, error) {
+    if off < 0 {
+        return 0, errors.New("multireader: negative offset")
+    }
+    // First part whose end lies beyond off.
+    i := sort.Search(len(m.parts), func(i int) bool { return m.starts[i]+m.parts[i].Size() > off })
+    n := 0
+    for ; i < len(m.parts) && n < len(p); i++ {
+        rel := off + int64(n) - m.starts[i]
+        want := int(minInt64(int64(len(p)-n), m.parts[i].Size()-rel))
+        k, err := m.parts[i].ReadAt(p[n:n+want], rel)
+        n += k
+        if err != nil && err != io.EOF {
+            return n, err
+        }
+        if k < want {
+            break
+        }
+    }
+    if n < len(p) {
+        return n, io.EOF
+    }
+    return n, nil
+}
+
+// ErrSplitArchive is returned when only one disk of a split ZIP is given.
+var ErrSplitArchive = errors.New("split zip: all parts are required")
+
+const (
+    zipEOCDSig      = 0x06054b50
+    zipEOCD64Sig    = 0x06064b50
+    zipEOCD64LocSig = 0x07064b50
+    zipCentralSig   = 0x02014b50
+    zipEOCDLen      = 22
+    zipEOCD64Len    = 56
+    zipEOCD64LocLen = 20
+    zipCentralLen   = 46
+    zipExtraZip64   = 0x0001
+    uint16max       = 0xffff
+    uint32max       = 0xffffffff
+)
+
+type zipEOCD struct {
+    disk, cdDisk     uint32
+    entries          uint64
+    cdSize, cdOffset uint64
+}
+
+// JoinSplitZip joins the disks of a split ZIP (.z01, .z02, ..., .zip, in
+// that order) into a single archive readable by archive/zip. Central
+// directory offsets in split sets are relative to the disk they point into,
+// so the directory is rewritten with absolute offsets (ZIP64 records are
+// kept or added as needed). Plain byte-split sets (.zip.001, .zip.002) only
+// need concatenation and are passed through.
+func JoinSplitZip(parts ...*io.SectionReader) (*io.SectionReader, error) {
+    whole := NewMultiReaderAt(parts...)
+    if len(parts) < 2 {
+        return io.NewSectionReader(whole, 0, whole.Size()), nil
+    }
+    end, eocdOff, err := readZipEOCD(whole, whole.Size())
+    if err != nil {
+        return nil, err
+    }
+    if end.disk == 0 {
+        return io.NewSectionReader(whole, 0, whole.Size()), nil
+    }
+    if int(end.disk) != len(parts)-1 {
+        return nil, fmt.Errorf("%w: archive has %d disks, got %d parts", ErrSplitArchive, end.disk+1, len(parts))
+    }
+    if int(end.cdDisk) >= len(parts) {
+        return nil, fmt.Errorf("%w: central directory on missing disk %d", zip.ErrFormat, end.cdDisk)
+    }
+
+    cdStart := whole.starts[end.cdDisk] + int64(end.cdOffset)
+    if end.cdSize > uint64(eocdOff) || cdStart+int64(end.cdSize) > eocdOff {
+        return nil, fmt.Errorf("%w: central directory out of range", zip.ErrFormat)
+    }
+    cd := make([]byte, end.cdSize)
+    if _, err := whole.ReadAt(cd, cdStart); err != nil {
+        return nil, fmt.Errorf("read central directory: %w", err)
+    }
+    newCD, err := rebaseCentralDirectory(cd, whole.starts)
+    if err != nil {
+        return nil, err
+    }
+
+    tail := new(bytes.Buffer)
+    tail.Write(newCD)
+    writeZipEOCD(tail, end.entries, uint64(len(newCD)), uint64(cdStart))
+    return io.NewSectionReader(NewMultiReaderAt(
+        io.NewSectionReader(whole, 0, cdStart),
+        io.NewSectionReader(bytes.NewReader(tail.Bytes()), 0, int64(tail.Len())),
+    ), 0, cdStart+int64(tail.Len())), nil
+}
+
+// readZipEOCD locates the end of central directory record, following the
+// ZIP64 locator when the classic record is saturated.
+func readZipEOCD(r io.ReaderAt, size int64) (zipEOCD, int64, error) {
+    var end zipEOCD
+    // Most archives have no comment, so try a short tail before reading
+    // enough to cover the longest one.
+    var buf []byte
+    var n int64
+    i := -1
+    for _, tail := range []int64{1024, zipEOCDLen + uint16max} {
+        n = minInt64(size, tail)
+        buf = make([]byte, n)
+        if _, err := r.ReadAt(buf, size-n); err != nil && err != io.EOF {
+            return end, 0, fmt.Errorf("read end of central directory: %w", err)
+        }
+        for i = len(buf) - zipEOCDLen; i >= 0; i-- {
+            if binary.LittleEndian.Uint32(buf[i:]) == zipEOCDSig {
+                break
+            }
+        }
+        if i >= 0 || n == size {
+            break
+        }
+    }
+    if i < 0 {
+        return end, 0, zip.ErrFormat
+    }
+    b := buf[i:]
+    end = zipEOCD{
+        disk:     uint32(binary.LittleEndian.Uint16(b[4:])),
+        cdDisk:   uint32(binary.LittleEndian.Uint16(b[6:])),
+        entries:  uint64(binary.LittleEndian.Uint16(b[10:])),
+        cdSize:   uint64(binary.LittleEndian.Uint32(b[12:])),
+        cdOffset: uint64(binary.LittleEndian.Uint32(b[16:])),
+    }
+    eocdOff := size - n + int64(i)
+    if end.disk != uint16max && end.cdDisk != uint16max && end.entries != uint16max &&
+        end.cdSize != uint32max && end.cdOffset != uint32max {
+        return end, eocdOff, nil
+    }
+
+    // ZIP64: the locator sits just before the classic record and gives the
+    // disk-relative position of the ZIP64 record, which in split sets is on
+    // the last disk along with the locator itself.
+    if eocdOff < zipEOCD64LocLen {
+        return end, eocdOff, nil
+    }
+    loc := make([]byte, zipEOCD64LocLen)
+    if _, err := r.ReadAt(loc, eocdOff-zipEOCD64LocLen); err != nil {
+        return end, 0, fmt.Errorf("read zip64 locator: %w", err)
+    }
+    if binary.LittleEndian.Uint32(loc) != zipEOCD64LocSig {
+        return end, eocdOff, nil
+    }
+    // Search backwards from the locator rather than trusting its
+    // disk-relative offset; the record is fixed-size without extensible data.
+    recOff := eocdOff - zipEOCD64LocLen - zipEOCD64Len
+    if recOff < 0 {
+        return end, 0, zip.ErrFormat
+    }
+    rec := make([]byte, zipEOCD64Len)
+    if _, err := r.ReadAt(rec, recOff); err != nil {
+        return end, 0, fmt.Errorf("read zip64 end of central directory: %w", err)
+    }
+    if binary.LittleEndian.Uint32(rec) != zipEOCD64Sig {
+        return end, 0, zip.ErrFormat
+    }
+    end = zipEOCD{
+        disk:     binary.LittleEndian.Uint32(rec[16:]),
+        cdDisk:   binary.LittleEndian.Uint32(rec[20:]),
+        entries:  binary.LittleEndian.Uint64(rec[32:]),
+        cdSize:   binary.LittleEndian.Uint64(rec[40:]),
+        cdOffset: binary.LittleEndian.Uint64(rec[48:]),
+    }
+    return end, recOff, nil
+}
+
+// rebaseCentralDirectory rewrites every central directory header so local
+// header offsets are absolute and all entries claim disk 0.
+func rebaseCentralDirectory(cd []byte, starts []int64) ([]byte, error) {
+    out := make([]byte, 0, len(cd)+len(cd)/8)
+    for len(cd) > 0 {
+        if len(cd) < zipCentralLen || binary.LittleEndian.Uint32(cd) != zipCentralSig {
+            return nil, fmt.Errorf("%w: bad central directory header", zip.ErrFormat)
+        }
+        nameLen := int(binary.LittleEndian.Uint16(cd[28:]))
+        extraLen := int(binary.LittleEndian.Uint16(cd[30:]))
+        commentLen := int(binary.LittleEndian.Uint16(cd[32:]))
+        total := zipCentralLen + nameLen + extraLen + commentLen
+        if len(cd) < total {
+            return nil, fmt.Errorf("%w: truncated central directory", zip.ErrFormat)
+        }
+        hdr := append([]byte(nil), cd[:zipCentralLen]...)
+        name := cd[zipCentralLen : zipCentralLen+nameLen]
+        extra := cd[zipCentralLen+nameLen : zipCentralLen+nameLen+extraLen]
+        comment := cd[zipCentralLen+nameLen+extraLen : total]
+        cd = cd[total:]
+
+        usize := uint64(binary.LittleEndian.Uint32(hdr[24:]))
+        csize := uint64(binary.LittleEndian.Uint32(hdr[20:]))
+        disk := uint64(binary.LittleEndian.Uint16(hdr[34:]))
+        offset := uint64(binary.LittleEndian.Uint32(hdr[42:]))
+
+        // Pull saturated fields from the ZIP64 extra and drop it; it is
+        // rebuilt below with whatever the rebased entry needs.
+        var rest []byte
+        for e := extra; len(e) >= 4; {
+            tag := binary.LittleEndian.Uint16(e)
+            size := int(binary.LittleEndian.Uint16(e[2:]))
+            if 4+size > len(e) {
+                break
+            }
+            if tag != zipExtraZip64 {
+                rest = append(rest, e[:4+size]...)
+                e = e[4+size:]
+                continue
+            }
+            f := e[4 : 4+size]
+            next := func(field *uint64, width int) {
+                if len(f) >= width {
+                    if width == 8 {
+                        *field = binary.LittleEndian.Uint64(f)
+                    } else {
+                        *field = uint64(binary.LittleEndian.Uint32(f))
+                    }
+                    f = f[width:]
+                }
+            }
+            if usize == uint32max {
+                next(&usize, 8)
+            }
+            if csize == uint32max {
+                next(&csize, 8)
+            }
+            if offset == uint32max {
+                next(&offset, 8)
+            }
+            if disk == uint16max {
+                next(&disk, 4)
+            }
+            e = e[4+size:]
+        }
+        if disk >= uint64(len(starts)) {
+            return nil, fmt.Errorf("%w: entry %q on missing disk %d", zip.ErrFormat, name, disk)
+        }
+        offset += uint64(starts[disk])
+
+        var z64 []byte
+        if usize >= uint32max {
+            z64 = binary.LittleEndian.AppendUint64(z64, usize)
+            usize = uint32max
+        }
+        if csize >= uint32max {
+            z64 = binary.LittleEndian.AppendUint64(z64, csize)
+            csize = uint32max
+        }
+        if offset >= uint32max {
+            z64 = binary.LittleEndian.AppendUint64(z64, offset)
+            offset = uint32max
+        }
+        newExtra := rest
+        if len(z64) > 0 {
+            field := binary.LittleEndian.AppendUint16(nil, zipExtraZip64)
+            field = binary.LittleEndian.AppendUint16(field, uint16(len(z64)))
+            newExtra = append(append(field, z64...), rest...)
+        }
+        if len(newExtra) > uint16max {
+            return nil, fmt.Errorf("%w: extra field too large for %q", zip.ErrFormat, name)
+        }
+
+        binary.LittleEndian.PutUint32(hdr[20:], uint32(csize))
+        binary.LittleEndian.PutUint32(hdr[24:], uint32(usize))
+        binary.LittleEndian.PutUint16(hdr[30:], uint16(len(newExtra)))
+        binary.LittleEndian.PutUint16(hdr[34:], 0)
+        binary.LittleEndian.PutUint32(hdr[42:], uint32(offset))
+        out = append(out, hdr...)
+        out = append(out, name...)
+        out = append(out, newExtra...)
+        out = append(out, comment...)
+    }
+    return out, nil
+}
+
+// writeZipEOCD appends a single-disk end of central directory, preceded by
+// ZIP64 records when any field overflows the classic format.
+func writeZipEOCD(w *bytes.Buffer, entries, cdSize, cdOffset uint64) {
+    if entries >= uint16max || cdSize >= uint32max || cdOffset >= uint32max {
+        rec := make([]byte, zipEOCD64Len)
+        binary.LittleEndian.PutUint32(rec, zipEOCD64Sig)
+        binary.LittleEndian.PutUint64(rec[4:], zipEOCD64Len-12)
+        binary.LittleEndian.PutUint16(rec[12:], 45) // version made by
+        binary.LittleEndian.PutUint16(rec[14:], 45) // version needed
+        binary.LittleEndian.PutUint64(rec[24:], entries)
+        binary.LittleEndian.PutUint64(rec[32:], entries)
+        binary.LittleEndian.PutUint64(rec[40:], cdSize)
+        binary.LittleEndian.PutUint64(rec[48:], cdOffset)
+        loc := make([]byte, zipEOCD64LocLen)
+        binary.LittleEndian.PutUint32(loc, zipEOCD64LocSig)
+        binary.LittleEndian.PutUint64(loc[8:], cdOffset+cdSize)
+        binary.LittleEndian.PutUint32(loc[16:], 1)
+        w.Write(rec)
+        w.Write(loc)
+        entries = min(entries, uint16max)
+        cdSize = min(cdSize, uint32max)
+        cdOffset = min(cdOffset, uint32max)
+    }
+    rec := make([]byte, zipEOCDLen)
+    binary.LittleEndian.PutUint32(rec, zipEOCDSig)
+    binary.LittleEndian.PutUint16(rec[8:], uint16(entries))
+    binary.LittleEndian.PutUint16(rec[10:], uint16(entries))
+    binary.LittleEndian.PutUint32(rec[12:], uint32(cdSize))
+    binary.LittleEndian.PutUint32(rec[16:], uint32(cdOffset))
+    w.Write(rec)
+}
+
+var (
+    splitZipDisk   = regexp.MustCompile(`(?i)^(.*)\.(z\d{2,}|zip)$`)
+    splitZipNumber = regexp.MustCompile(`(?i)^(.*\.zip)\.\d{3}$`)
+)
+
+// SplitZipParts returns the files of the split ZIP set that path belongs
+// to, in disk order: name.z01, name.z02, ..., name.zip for spanned sets or
+// name.zip.001, name.zip.002, ... for byte-split ones. A path that is not
+// part of a set is returned on its own.
+func SplitZipParts(path string) ([]string, error) {
+    dir, file := filepath.Split(path)
+    if m := splitZipNumber.FindStringSubmatch(file); m != nil {
+        var parts []string
+        for i := 1; ; i++ {
+            p := filepath.Join(dir, fmt.Sprintf("%s.%03d", m[1], i))
+            if _, err := os.Stat(p); err != nil {
+                break
+            }
+            parts = append(parts, p)
+        }
+        if len(parts) == 0 {
+            return nil, fmt.Errorf("split zip: %s.001 not found", m[1])
+        }
+        return parts, nil
+    }
+
+    m := splitZipDisk.FindStringSubmatch(file)
+    if m == nil {
+        return []string{path}, nil
+    }
+    // Disk extensions follow the case of the final .zip where it exists.
+    ext := "z"
+    last := filepath.Join(dir, m[1]+".zip")
+    if strings.HasPrefix(m[2], "Z") {
+        ext, last = "Z", filepath.Join(dir, m[1]+".ZIP")
+    }
+    var parts []string
+    for i := 1; ; i++ {
+        p := filepath.Join(dir, fmt.Sprintf("%s.%s%02d", m[1], ext, i))
+        if _, err := os.Stat(p); err != nil {
+            break
+        }
+        parts = append(parts, p)
+    }
+    if len(parts) == 0 {
+        return []string{path}, nil
+    }
+    if _, err := os.Stat(last); err != nil {
+        return nil, fmt.Errorf("%w: %s not found", ErrSplitArchive, filepath.Base(last))
+    }
+    return append(parts, last), nil
+}
+
+// openSplitZip opens the local files of a split set as one archive.
+func openSplitZip(paths []string, opts ArchiveOptions) (Archive, error) {
+    var files multiCloser
+    parts := make([]*io.SectionReader, 0, len(paths))
+    for _, p := range paths {
+        f, err := os.Open(p)
+        if err != nil {
+            files.Close()
+            return nil, fmt.Errorf("open archive: %w", err)
+        }
+        files = append(files, f)
+        st, err := f.Stat()
+        if err != nil {
+            files.Close()
+            return nil, fmt.Errorf("stat archive: %w", err)
+        }
+        parts = append(parts, io.NewSectionReader(f, 0, st.Size()))
+    }
+    joined, err := JoinSplitZip(parts...)
+    if err != nil {
+        files.Close()
+        return nil, err
+    }
+    a, err := newZipArchive(joined, joined.Size(), files, opts)
+    if err != nil {
+        files.Close()
+        return nil, err
+    }
+    return a, nil
+}
+
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+    var first error
+    for _, c := range m {
+        if err := c.Close(); err != nil && first == nil {
+            first = err
+        }
+    }
+    return first
+}
@@ -0,0 +1,135 @@
+package streamline_core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrVerifyFailed matches entries whose written contents do not agree with
+// the archive's recorded size or CRC32, and missing files under Verify.
+var ErrVerifyFailed = errors.New("verification failed")
+
+// checkContents compares what was read for e against its header.
+func checkContents(e Entry, size int64, crc uint32) error {
+    if e.Size >= 0 && size != e.Size {
+        return fmt.Errorf("%w: size %d, want %d", ErrVerifyFailed, size, e.Size)
+    }
+    if e.HasCRC && crc != e.CRC32 {
+        return fmt.Errorf("%w: crc32 %08x, want %08x", ErrVerifyFailed, crc, e.CRC32)
+    }
+    return nil
+}
+
+// VerifySummary reports what Verify checked.
+type VerifySummary struct {
+    Total    int
+    Verified int
+    Skipped  int
+    Errors   []*EntryError
+}
+
+// Verify re-checks a previously extracted tree under outDir against a,
+// without writing anything. Entries with a recorded CRC32 are compared by
+// reading only the local file; the others are read from the archive too.
+// opts.Select, opts.Paths and opts.OnEntry are honoured; other options are
+// ignored.
+func Verify(ctx context.Context, a Archive, outDir string, opts ExtractOptions) (*VerifySummary, error) {
+    w, ok := a.(walker)
+    if !ok {
+        return nil, fmt.Errorf("verify: %T cannot be walked", a)
+    }
+    summary := &VerifySummary{}
+    mapper := newPathMapper(opts.Paths)
+    err := w.walk(ctx, func(e Entry, open entryOpener) error {
+        summary.Total++
+        name, ok := "", opts.Select == nil || opts.Select(e)
+        if ok {
+            name, ok = mapper.mapName(e)
+        }
+        if !ok {
+            summary.Skipped++
+            return nil
+        }
+        targetPath := filepath.Join(outDir, filepath.FromSlash(name))
+        var err error
+        if strings.HasPrefix(name, "/") || !IsPathWithinBase(outDir, targetPath) {
+            err = fmt.Errorf("illegal path: %s", name)
+        } else {
+            err = verifyEntry(ctx, e, open, targetPath)
+        }
+        if opts.OnEntry != nil {
+            opts.OnEntry(e, targetPath, err)
+        }
+        if err != nil {
+            summary.Errors = append(summary.Errors, &EntryError{Name: e.Name, Err: err})
+        } else {
+            summary.Verified++
+        }
+        return ctx.Err()
+    })
+    return summary, err
+}
+
+func verifyEntry(ctx context.Context, e Entry, open entryOpener, targetPath string) error {
+    st, err := os.Lstat(targetPath)
+    if err != nil {
+        if errors.Is(err, os.ErrNotExist) {
+            return fmt.Errorf("%w: missing", ErrVerifyFailed)
+        }
+        return err
+    }
+    if e.IsDir {
+        if !st.IsDir() {
+            return fmt.Errorf("%w: not a directory", ErrVerifyFailed)
+        }
+        return nil
+    }
+
+    // A symlink preserved on disk is compared by its target, which is what
+    // the archive stores as the entry's contents.
+    var local io.Reader
+    if st.Mode()&os.ModeSymlink != 0 {
+        target, err := os.Readlink(targetPath)
+        if err != nil {
+            return err
+        }
+        local = strings.NewReader(filepath.ToSlash(target))
+    } else {
+        f, err := os.Open(targetPath)
+        if err != nil {
+            return err
+        }
+        defer f.Close()
+        local = f
+    }
+    size, crc, err := checksum(ctx, local)
+    if err != nil {
+        return fmt.Errorf("read file: %w", err)
+    }
+
+    want := e
+    if !e.HasCRC {
+        rc, err := open()
+        if err != nil {
+            return fmt.Errorf("open entry: %w", err)
+        }
+        defer rc.Close()
+        if want.Size, want.CRC32, err = checksum(ctx, rc); err != nil {
+            return fmt.Errorf("read entry: %w", err)
+        }
+        want.HasCRC = true
+    }
+    return checkContents(want, size, crc)
+}
+
+func checksum(ctx context.Context, r io.Reader) (int64, uint32, error) {
+    hash := crc32.NewIEEE()
+    n, err := io.CopyBuffer(hash, NewContextReader(ctx, r), make([]byte, BufferSize))
+    return n, hash.Sum32(), err
+}
@@ -3,8 +3,8 @@ package streamline_core
 import (
 	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,114 +25,131 @@ func IsPathWithinBase(base, target string) bool {
 }
 
 func ExtractFile(f *zip.File, targetPath string) error {
-    if f.FileInfo().IsDir() {
-        return os.MkdirAll(targetPath, 0o755)
-    }
-    if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
-        return fmt.Errorf("mkdir parents: %w", err)
-    }
-    rc, err := f.Open()
-    if err != nil {
-        return fmt.Errorf("open zip entry: %w", err)
-    }
-    defer rc.Close()
-    outF, err := os.Create(targetPath)
-    if err != nil {
-        return fmt.Errorf("create file: %w", err)
-    }
-    defer outF.Close()
-    if _, err := io.CopyBuffer(outF, rc, make([]byte, BufferSize)); err != nil {
-        return fmt.Errorf("write file: %w", err)
-    }
-    return nil
+    return ExtractFileContext(context.Background(), f, targetPath)
+}
+
+// ExtractFileContext is ExtractFile with a cancellation point on every
+// buffered read, so a single large entry can be aborted mid-copy. The
+// per-entry DefaultLimits apply to what the decompressor actually yields,
+// and targetPath only appears once the contents match the entry's size and
+// CRC32.
+func ExtractFileContext(ctx context.Context, f *zip.File, targetPath string) error {
+    e := zipEntry(f)
+    guard := newLimitGuard(DefaultLimits)
+    if err := guard.admit(e); err != nil {
+        return err
+    }
+    return writeEntry(ctx, e, guard.opener(e, f.Open), targetPath)
 }
 
+// ShouldExtract applies a single include and exclude pattern, either of
+// which may be empty, with the matching rules of Filter.
 func ShouldExtract(name string, include, exclude string) bool {
+    var f Filter
     if include != "" {
-        matched, _ := filepath.Match(include, filepath.Base(name))
-        if !matched {
-            return false
-        }
+        f.Include = []string{include}
     }
     if exclude != "" {
-        matched, _ := filepath.Match(exclude, filepath.Base(name))
-        if matched {
-            return false
-        }
+        f.Exclude = []string{exclude}
     }
-    return true
+    return f.Match(Entry{Name: name})
 }
 
+// ExtractZip extracts an archive of any supported format (the name predates
+// tar and 7z support) into outputDir, filtered by ShouldExtract.
 func ExtractZip(zipPath, outputDir, include, exclude string) error {
-    r, err := zip.OpenReader(zipPath)
-    if err != nil {
-        return fmt.Errorf("open zip: %w", err)
-    }
-    defer r.Close()
-
-    for _, f := range r.File {
-        targetPath := filepath.Join(outputDir, f.Name)
+    _, err := ExtractZipWithOptions(context.Background(), zipPath, outputDir, ExtractOptions{
+        Select: func(e Entry) bool { return ShouldExtract(e.Name, include, exclude) },
+        Limits: DefaultLimits,
+    })
+    return err
+}
 
-        if !IsPathWithinBase(outputDir, targetPath) {
-            return fmt.Errorf("illegal path: %s", targetPath)
-        }
-        if !ShouldExtract(f.Name, include, exclude) {
-            continue
-        }
-        if err := ExtractFile(f, targetPath); err != nil {
-            return fmt.Errorf("extract %s: %w", f.Name, err)
-        }
+// ExtractZipWithOptions opens the archive at zipPath and extracts it with
+// opts, e.g. to remap paths with opts.Paths.
+func ExtractZipWithOptions(ctx context.Context, zipPath, outputDir string, opts ExtractOptions) (*ExtractSummary, error) {
+    a, err := OpenArchive(zipPath)
+    if err != nil {
+        return nil, err
     }
-    return nil
+    defer a.Close()
+    return a.Extract(ctx, outputDir, opts)
 }
 
-// List files inside a ZIP without extracting
+// List files inside an archive without extracting
 func ListZipFiles(zipPath string) ([]string, error) {
-    r, err := zip.OpenReader(zipPath)
+    entries, err := ListEntries(zipPath)
     if err != nil {
-        return nil, fmt.Errorf("open zip: %w", err)
+        return nil, err
     }
-    defer r.Close()
-
     var files []string
-    for _, f := range r.File {
-        files = append(files, f.Name)
+    for _, e := range entries {
+        files = append(files, e.Name)
     }
     return files, nil
 }
 
+// ListEntries returns the entries of the archive at zipPath, in archive
+// order, with sizes, method, CRC, times, mode, encryption and comments.
+func ListEntries(zipPath string) ([]Entry, error) {
+    a, err := OpenArchive(zipPath)
+    if err != nil {
+        return nil, err
+    }
+    defer a.Close()
+    return a.List()
+}
+
 // Extract only selected files, supports cancellation
 func ExtractSelectedFiles(ctx context.Context, zipPath, outputDir string, selected []string, logChan chan<- string) error {
-    r, err := zip.OpenReader(zipPath)
+    _, err := ExtractSelectedFilesWithOptions(ctx, zipPath, outputDir, selected, logChan, ExtractOptions{Limits: DefaultLimits})
+    return err
+}
+
+// ExtractSelectedFilesWithOptions is ExtractSelectedFiles with caller-set
+// limits, conflict policy and error handling. opts.Select and opts.OnEntry
+// are wrapped, not replaced, so callers can still observe entries.
+func ExtractSelectedFilesWithOptions(ctx context.Context, zipPath, outputDir string, selected []string, logChan chan<- string, opts ExtractOptions) (*ExtractSummary, error) {
+    a, err := OpenArchive(zipPath)
     if err != nil {
-        return fmt.Errorf("open zip: %w", err)
+        return nil, err
     }
-    defer r.Close()
+    defer a.Close()
 
     selectedSet := make(map[string]bool)
     for _, s := range selected {
         selectedSet[s] = true
     }
 
-    for _, f := range r.File {
-        select {
-        case <-ctx.Done():
-            logChan <- "Aborted"
-            return ctx.Err()
-        default:
+    sel, onEntry := opts.Select, opts.OnEntry
+    opts.Select = func(e Entry) bool {
+        if !selectedSet[e.Name] || (sel != nil && !sel(e)) {
+            return false
         }
-
-        if !selectedSet[f.Name] {
-            continue
+        logChan <- fmt.Sprintf("Extracting %s...", e.Name)
+        return true
+    }
+    opts.OnEntry = func(e Entry, targetPath string, err error) {
+        if errors.Is(err, ErrSkippedExisting) {
+            logChan <- fmt.Sprintf("- Skipped (exists): %s", e.Name)
+        } else if err == nil {
+            logChan <- fmt.Sprintf("✓ Done: %s", e.Name)
+        } else if opts.ContinueOnError {
+            logChan <- fmt.Sprintf("✗ Failed: %s: %v", e.Name, err)
         }
-
-        logChan <- fmt.Sprintf("Extracting %s...", f.Name)
-        targetPath := filepath.Join(outputDir, f.Name)
-        if err := ExtractFile(f, targetPath); err != nil {
-            return fmt.Errorf("extract %s: %w", f.Name, err)
+        if onEntry != nil {
+            onEntry(e, targetPath, err)
+        }
+    }
+    summary, err := a.Extract(ctx, outputDir, opts)
+    if err != nil {
+        if ctx.Err() != nil {
+            logChan <- "Aborted"
+            return summary, ctx.Err()
         }
+        return summary, err
     }
 
     logChan <- "Extraction complete."
-    return nil
+    return summary, nil
 }
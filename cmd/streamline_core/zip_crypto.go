@@ -0,0 +1,292 @@
+package streamline_core
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+var (
+    // ErrPasswordRequired is returned when an encrypted entry (or an archive
+    // with encrypted headers) is read without a password.
+    ErrPasswordRequired = errors.New("password required")
+    // ErrBadPassword is returned when the supplied password does not match.
+    ErrBadPassword = errors.New("incorrect password")
+)
+
+const (
+    zipFlagEncrypted      = 0x1
+    zipFlagDataDescriptor = 0x8
+    zipMethodAES          = 99
+    zipExtraAES           = 0x9901
+    zipCryptoHeaderLen    = 12
+    aesPasswordVerifyLen  = 2
+    aesAuthCodeLen        = 10
+)
+
+// isEncrypted reports whether a ZIP entry uses ZipCrypto or WinZip AES.
+func isEncrypted(f *zip.File) bool {
+    return f.Flags&zipFlagEncrypted != 0
+}
+
+// openEncrypted decrypts and decompresses an encrypted ZIP entry. Go's
+// archive/zip has no encryption support, so the raw entry data is read and
+// decoded here.
+func openEncrypted(f *zip.File, password string) (io.ReadCloser, error) {
+    if password == "" {
+        return nil, ErrPasswordRequired
+    }
+    raw, err := f.OpenRaw()
+    if err != nil {
+        return nil, err
+    }
+
+    method := f.Method
+    checkCRC := true
+    var plain io.Reader
+    if f.Method == zipMethodAES {
+        aesInfo, ok := parseAESExtra(f.Extra)
+        if !ok {
+            return nil, fmt.Errorf("%w: missing AES extra field", zip.ErrFormat)
+        }
+        method = aesInfo.method
+        // AE-2 zeroes the CRC; the HMAC authenticates the data instead.
+        checkCRC = aesInfo.version == 1
+        plain, err = newAESReader(raw, int64(f.CompressedSize64), aesInfo.keyLen, password)
+    } else {
+        check := byte(f.CRC32 >> 24)
+        if f.Flags&zipFlagDataDescriptor != 0 {
+            check = byte(f.ModifiedTime >> 8)
+        }
+        plain, err = newZipCryptoReader(raw, password, check)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    var rc io.ReadCloser
+    switch method {
+    case zip.Store:
+        rc = io.NopCloser(plain)
+    case zip.Deflate:
+        rc = flate.NewReader(plain)
+    default:
+        return nil, zip.ErrAlgorithm
+    }
+    if !checkCRC {
+        return rc, nil
+    }
+    return &crcReader{rc: rc, hash: crc32.NewIEEE(), want: f.CRC32, size: f.UncompressedSize64}, nil
+}
+
+// crcReader verifies the CRC32 and length of decrypted data at EOF, the
+// same check archive/zip performs for unencrypted entries.
+type crcReader struct {
+    rc   io.ReadCloser
+    hash hash.Hash32
+    want uint32
+    size uint64
+    n    uint64
+}
+
+func (r *crcReader) Read(p []byte) (int, error) {
+    n, err := r.rc.Read(p)
+    r.hash.Write(p[:n])
+    r.n += uint64(n)
+    if err == io.EOF && (r.n != r.size || r.hash.Sum32() != r.want) {
+        return n, zip.ErrChecksum
+    }
+    return n, err
+}
+
+func (r *crcReader) Close() error { return r.rc.Close() }
+
+// zipCrypto implements the traditional PKWARE stream cipher.
+type zipCrypto struct {
+    k0, k1, k2 uint32
+}
+
+func newZipCrypto(password string) *zipCrypto {
+    z := &zipCrypto{k0: 0x12345678, k1: 0x23456789, k2: 0x34567890}
+    for i := 0; i < len(password); i++ {
+        z.update(password[i])
+    }
+    return z
+}
+
+func crc32Update(crc uint32, b byte) uint32 {
+    return crc32.IEEETable[byte(crc)^b] ^ crc>>8
+}
+
+func (z *zipCrypto) update(b byte) {
+    z.k0 = crc32Update(z.k0, b)
+    z.k1 = (z.k1+z.k0&0xff)*134775813 + 1
+    z.k2 = crc32Update(z.k2, byte(z.k1>>24))
+}
+
+func (z *zipCrypto) decrypt(p []byte) {
+    for i, c := range p {
+        t := z.k2 | 2
+        b := c ^ byte((t*(t^1))>>8)
+        z.update(b)
+        p[i] = b
+    }
+}
+
+type zipCryptoReader struct {
+    r io.Reader
+    z *zipCrypto
+}
+
+func newZipCryptoReader(r io.Reader, password string, check byte) (io.Reader, error) {
+    z := newZipCrypto(password)
+    hdr := make([]byte, zipCryptoHeaderLen)
+    if _, err := io.ReadFull(r, hdr); err != nil {
+        return nil, err
+    }
+    z.decrypt(hdr)
+    if hdr[zipCryptoHeaderLen-1] != check {
+        return nil, ErrBadPassword
+    }
+    return &zipCryptoReader{r: r, z: z}, nil
+}
+
+func (r *zipCryptoReader) Read(p []byte) (int, error) {
+    n, err := r.r.Read(p)
+    r.z.decrypt(p[:n])
+    return n, err
+}
+
+type aesExtra struct {
+    version uint16
+    keyLen  int
+    method  uint16
+}
+
+// parseAESExtra reads the WinZip AES extra field (0x9901).
+func parseAESExtra(extra []byte) (aesExtra, bool) {
+    for len(extra) >= 4 {
+        tag := binary.LittleEndian.Uint16(extra)
+        size := int(binary.LittleEndian.Uint16(extra[2:]))
+        extra = extra[4:]
+        if size > len(extra) {
+            break
+        }
+        if tag == zipExtraAES && size >= 7 {
+            d := extra[:size]
+            info := aesExtra{
+                version: binary.LittleEndian.Uint16(d),
+                method:  binary.LittleEndian.Uint16(d[5:]),
+            }
+            switch d[4] {
+            case 1:
+                info.keyLen = 16
+            case 2:
+                info.keyLen = 24
+            case 3:
+                info.keyLen = 32
+            default:
+                return aesExtra{}, false
+            }
+            return info, true
+        }
+        extra = extra[size:]
+    }
+    return aesExtra{}, false
+}
+
+// aesReader decrypts WinZip AES data: AES-CTR with a little-endian counter
+// starting at 1, authenticated by a truncated HMAC-SHA1 checked at EOF.
+type aesReader struct {
+    r       io.Reader // ciphertext followed by the auth code
+    remain  int64     // ciphertext bytes not yet read
+    block   cipher.Block
+    counter [aes.BlockSize]byte
+    stream  [aes.BlockSize]byte
+    used    int
+    mac     hash.Hash
+}
+
+func newAESReader(r io.Reader, compressed int64, keyLen int, password string) (io.Reader, error) {
+    saltLen := keyLen / 2
+    dataLen := compressed - int64(saltLen) - aesPasswordVerifyLen - aesAuthCodeLen
+    if dataLen < 0 {
+        return nil, zip.ErrFormat
+    }
+    hdr := make([]byte, saltLen+aesPasswordVerifyLen)
+    if _, err := io.ReadFull(r, hdr); err != nil {
+        return nil, err
+    }
+    key, err := pbkdf2.Key(sha1.New, password, hdr[:saltLen], 1000, 2*keyLen+aesPasswordVerifyLen)
+    if err != nil {
+        return nil, err
+    }
+    if !bytes.Equal(key[2*keyLen:], hdr[saltLen:]) {
+        return nil, ErrBadPassword
+    }
+    block, err := aes.NewCipher(key[:keyLen])
+    if err != nil {
+        return nil, err
+    }
+    return &aesReader{
+        r:      r,
+        remain: dataLen,
+        block:  block,
+        used:   aes.BlockSize,
+        mac:    hmac.New(sha1.New, key[keyLen:2*keyLen]),
+    }, nil
+}
+
+func (r *aesReader) Read(p []byte) (int, error) {
+    if r.remain == 0 {
+        code := make([]byte, aesAuthCodeLen)
+        if _, err := io.ReadFull(r.r, code); err != nil {
+            return 0, err
+        }
+        if !hmac.Equal(code, r.mac.Sum(nil)[:aesAuthCodeLen]) {
+            return 0, zip.ErrChecksum
+        }
+        return 0, io.EOF
+    }
+    if int64(len(p)) > r.remain {
+        p = p[:r.remain]
+    }
+    n, err := r.r.Read(p)
+    r.remain -= int64(n)
+    r.mac.Write(p[:n])
+    for i := 0; i < n; i++ {
+        if r.used == aes.BlockSize {
+            r.nextBlock()
+        }
+        p[i] ^= r.stream[r.used]
+        r.used++
+    }
+    if err == io.EOF && r.remain > 0 {
+        err = io.ErrUnexpectedEOF
+    } else if err == io.EOF {
+        err = nil
+    }
+    return n, err
+}
+
+func (r *aesReader) nextBlock() {
+    for i := range r.counter {
+        r.counter[i]++
+        if r.counter[i] != 0 {
+            break
+        }
+    }
+    r.block.Encrypt(r.stream[:], r.counter[:])
+    r.used = 0
+}
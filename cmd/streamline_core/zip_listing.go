@@ -0,0 +1,142 @@
+package streamline_core
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ListZipDirectory lists a ZIP from its end-of-central-directory records and
+// central directory alone, never touching entry data. Each region is read
+// once with a ReadAt sized to fit it, so over a reader that turns every
+// ReadAt into one range request, listing costs a few requests totalling
+// about the size of the directory. Data that does not end in a ZIP
+// directory gives ErrUnsupportedFormat.
+func ListZipDirectory(r io.ReaderAt, size int64) ([]Entry, error) {
+    sr := &spanReaderAt{r: r, size: size}
+    end, endOff, err := readZipEOCD(sr, size)
+    if errors.Is(err, zip.ErrFormat) {
+        return nil, fmt.Errorf("%w: no zip central directory", ErrUnsupportedFormat)
+    } else if err != nil {
+        return nil, err
+    }
+    if end.disk > 0 {
+        return nil, fmt.Errorf("%w: this is the last of %d disks", ErrSplitArchive, end.disk+1)
+    }
+    // The directory sits right before its end record, wherever its recorded
+    // offset says (self-extracting archives have data prepended).
+    if end.cdSize > uint64(endOff) {
+        return nil, fmt.Errorf("zip reader: %w", zip.ErrFormat)
+    }
+    if err := sr.fill(endOff-int64(end.cdSize), endOff); err != nil {
+        return nil, fmt.Errorf("read central directory: %w", err)
+    }
+
+    zr, err := zip.NewReader(sr, size)
+    if err != nil {
+        return nil, fmt.Errorf("zip reader: %w", err)
+    }
+    entries := make([]Entry, 0, len(zr.File))
+    for _, f := range zr.File {
+        entries = append(entries, zipEntry(f))
+    }
+    return entries, nil
+}
+
+// spanReaderAt remembers every byte range read through it and serves
+// repeated reads from memory, fetching only the parts not yet seen. That
+// lets archive/zip re-read the end records and walk the directory in small
+// steps without each step reaching the underlying reader.
+type spanReaderAt struct {
+    r    io.ReaderAt
+    size int64
+
+    mu    sync.Mutex
+    spans []span // sorted, non-overlapping, non-adjacent
+}
+
+type span struct {
+    off  int64
+    data []byte
+}
+
+func (s span) end() int64 { return s.off + int64(len(s.data)) }
+
+func (sr *spanReaderAt) ReadAt(p []byte, off int64) (int, error) {
+    if off < 0 {
+        return 0, fmt.Errorf("negative offset")
+    }
+    if off >= sr.size {
+        return 0, io.EOF
+    }
+    end := minInt64(off+int64(len(p)), sr.size)
+    if err := sr.fill(off, end); err != nil {
+        return 0, err
+    }
+    sr.mu.Lock()
+    defer sr.mu.Unlock()
+    // fill leaves [off, end) inside a single merged span.
+    i := sort.Search(len(sr.spans), func(i int) bool { return sr.spans[i].end() > off })
+    n := copy(p, sr.spans[i].data[off-sr.spans[i].off:end-sr.spans[i].off])
+    if n < len(p) {
+        return n, io.EOF
+    }
+    return n, nil
+}
+
+// fill reads the parts of [off, end) not already held, one ReadAt per gap.
+func (sr *spanReaderAt) fill(off, end int64) error {
+    sr.mu.Lock()
+    defer sr.mu.Unlock()
+    pos := off
+    for _, s := range append([]span(nil), sr.spans...) {
+        if pos >= end {
+            break
+        }
+        if s.end() <= pos {
+            continue
+        }
+        if s.off > pos {
+            if err := sr.fetch(pos, minInt64(s.off, end)); err != nil {
+                return err
+            }
+        }
+        pos = s.end()
+    }
+    if pos < end {
+        return sr.fetch(pos, end)
+    }
+    return nil
+}
+
+// fetch reads [off, end) from the underlying reader and merges it into the
+// held spans. The caller holds sr.mu.
+func (sr *spanReaderAt) fetch(off, end int64) error {
+    buf := make([]byte, end-off)
+    if n, err := sr.r.ReadAt(buf, off); n < len(buf) {
+        if err == nil || err == io.EOF {
+            err = io.ErrUnexpectedEOF
+        }
+        return err
+    }
+    merged := span{off: off, data: buf}
+    var kept []span
+    for _, s := range sr.spans {
+        if s.end() < merged.off || s.off > merged.end() {
+            kept = append(kept, s)
+            continue
+        }
+        lo, hi := min(s.off, merged.off), max(s.end(), merged.end())
+        data := make([]byte, hi-lo)
+        copy(data[s.off-lo:], s.data)
+        copy(data[merged.off-lo:], merged.data)
+        merged = span{off: lo, data: data}
+    }
+    kept = append(kept, merged)
+    sort.Slice(kept, func(i, j int) bool { return kept[i].off < kept[j].off })
+    sr.spans = kept
+    return nil
+}
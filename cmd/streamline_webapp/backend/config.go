@@ -3,8 +3,14 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	streamline_core "Streamline/cmd/streamline_core"
+	"Streamline/internal/downloader"
 
 	"github.com/joho/godotenv"
 )
@@ -18,6 +24,34 @@ type Config struct {
 	MaxFileSize     int64
 	MaxConcurrent   int
 
+	// Extraction guardrails (0 = unlimited)
+	MaxExtractBytes     int64
+	MaxEntries          int
+	MaxCompressionRatio int
+	MaxNestingDepth     int
+
+	// Filter rules applied to every listing and extraction; shared with the
+	// CLI through STREAMLINE_FILTER_FILE
+	FilterFile string
+
+	// Drive request retries
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+
+	// Resumable uploads for /api/download; unfinished sessions are kept in
+	// UploadDir so a repeated request resumes them (empty disables)
+	UploadChunkMB int
+	UploadDir     string
+
+	// Server directory /api/pack may read from and write to; sourceDir and
+	// outPath must lie inside it (empty disables server paths)
+	PackBaseDir string
+
+	// Networks /api/download may fetch from besides public addresses, as
+	// comma-separated CIDRs or IPs (empty allows public addresses only)
+	DownloadAllowNets string
+
 	// Google OAuth
 	GoogleClientID     string
 	GoogleClientSecret string
@@ -66,6 +100,28 @@ func LoadConfig() (*Config, error) {
 		MaxFileSize:    int64(getEnvInt("MAX_FILE_SIZE", 10*1024*1024*1024)), // 10GB default
 		MaxConcurrent:  getEnvInt("MAX_CONCURRENT", 5),
 
+		// Extraction guardrails
+		MaxExtractBytes:     int64(getEnvInt("MAX_EXTRACT_BYTES", 0)),
+		MaxEntries:          getEnvInt("MAX_ENTRIES", streamline_core.DefaultLimits.MaxEntries),
+		MaxCompressionRatio: getEnvInt("MAX_COMPRESSION_RATIO", int(streamline_core.DefaultLimits.MaxRatio)),
+		MaxNestingDepth:     getEnvInt("MAX_NESTING_DEPTH", streamline_core.DefaultLimits.MaxDepth),
+		FilterFile:          streamline_core.ResolveFilterFile(os.Getenv("STREAMLINE_FILTER_FILE")),
+
+		// Drive request retries
+		RetryMaxAttempts: getEnvInt("RETRY_MAX_ATTEMPTS", downloader.DefaultRetryPolicy.MaxAttempts),
+		RetryBaseDelay:   time.Duration(getEnvInt("RETRY_BASE_DELAY_MS", int(downloader.DefaultRetryPolicy.BaseDelay/time.Millisecond))) * time.Millisecond,
+		RetryMaxDelay:    time.Duration(getEnvInt("RETRY_MAX_DELAY_MS", int(downloader.DefaultRetryPolicy.MaxDelay/time.Millisecond))) * time.Millisecond,
+
+		// Resumable uploads
+		UploadChunkMB: getEnvInt("UPLOAD_CHUNK_MB", downloader.DefaultUploadChunkSize>>20),
+		UploadDir:     getEnv("STREAMLINE_UPLOAD_DIR", "upload_sessions"),
+
+		// Pack base directory
+		PackBaseDir: getEnv("PACK_BASE_DIR", "packs"),
+
+		// Download address allowlist
+		DownloadAllowNets: getEnv("DOWNLOAD_ALLOW_NETS", ""),
+
 		// Google OAuth
 		GoogleClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
 		GoogleClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
@@ -121,6 +177,26 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("MAX_CONCURRENT must be greater than 0")
 	}
 
+	if c.MaxExtractBytes < 0 || c.MaxEntries < 0 || c.MaxCompressionRatio < 0 || c.MaxNestingDepth < 0 {
+		return fmt.Errorf("MAX_EXTRACT_BYTES, MAX_ENTRIES, MAX_COMPRESSION_RATIO and MAX_NESTING_DEPTH must not be negative")
+	}
+
+	if c.RetryMaxAttempts <= 0 {
+		return fmt.Errorf("RETRY_MAX_ATTEMPTS must be greater than 0")
+	}
+
+	if c.RetryBaseDelay <= 0 || c.RetryMaxDelay < c.RetryBaseDelay {
+		return fmt.Errorf("RETRY_BASE_DELAY_MS must be positive and not exceed RETRY_MAX_DELAY_MS")
+	}
+
+	if c.UploadChunkMB <= 0 {
+		return fmt.Errorf("UPLOAD_CHUNK_MB must be greater than 0")
+	}
+
+	if _, err := c.AllowNets(); err != nil {
+		return fmt.Errorf("DOWNLOAD_ALLOW_NETS: %w", err)
+	}
+
 	return nil
 }
 
@@ -168,8 +244,69 @@ func (c *Config) LogConfig() {
 	log.Printf("Timeout: %d seconds", c.TimeoutSeconds)
 	log.Printf("Max File Size: %d bytes (%.2f GB)", c.MaxFileSize, float64(c.MaxFileSize)/1024/1024/1024)
 	log.Printf("Max Concurrent: %d", c.MaxConcurrent)
+	log.Printf("Extraction Limits: %d bytes total, %d entries, ratio %d:1, depth %d (0 = unlimited)",
+		c.MaxExtractBytes, c.MaxEntries, c.MaxCompressionRatio, c.MaxNestingDepth)
+	if c.FilterFile != "" {
+		log.Printf("Filter File: %s", c.FilterFile)
+	}
+	log.Printf("Retry: %d attempts, backoff %v-%v", c.RetryMaxAttempts, c.RetryBaseDelay, c.RetryMaxDelay)
+	log.Printf("Uploads: %d MB chunks, sessions in %q", c.UploadChunkMB, c.UploadDir)
+	log.Printf("Pack Base Directory: %q", c.PackBaseDir)
+	if c.DownloadAllowNets != "" {
+		log.Printf("Download Allowed Networks: %s", c.DownloadAllowNets)
+	}
 	log.Printf("Log Directory: %s", c.LogDir)
 	log.Printf("Debug Mode: %v", c.Debug)
 	log.Printf("Version: %s", c.Version)
 	log.Println("===========================")
-}
\ No newline at end of file
+}
+
+// RetryPolicy returns the Drive retry policy described by the configuration
+func (c *Config) RetryPolicy() downloader.RetryPolicy {
+	return downloader.RetryPolicy{
+		MaxAttempts: c.RetryMaxAttempts,
+		BaseDelay:   c.RetryBaseDelay,
+		MaxDelay:    c.RetryMaxDelay,
+	}
+}
+
+// AllowNets parses DownloadAllowNets; a bare IP allows just that address
+func (c *Config) AllowNets() ([]netip.Prefix, error) {
+	var nets []netip.Prefix
+	for _, field := range strings.Split(c.DownloadAllowNets, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(field); err == nil {
+			nets = append(nets, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", field)
+		}
+		nets = append(nets, p.Masked())
+	}
+	return nets, nil
+}
+
+// Filter loads the configured filter rules, or returns nil if there are none
+func (c *Config) Filter() (*streamline_core.Filter, error) {
+	if c.FilterFile == "" {
+		return nil, nil
+	}
+	return streamline_core.LoadFilterFile(c.FilterFile)
+}
+
+// ExtractLimits returns the extraction guardrails described by the
+// configuration. MAX_FILE_SIZE caps every extracted entry.
+func (c *Config) ExtractLimits() streamline_core.Limits {
+	return streamline_core.Limits{
+		MaxTotalBytes: c.MaxExtractBytes,
+		MaxEntryBytes: c.MaxFileSize,
+		MaxEntries:    c.MaxEntries,
+		MaxRatio:      float64(c.MaxCompressionRatio),
+		MaxDepth:      c.MaxNestingDepth,
+	}
+}
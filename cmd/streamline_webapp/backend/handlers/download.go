@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+
+	"Streamline/cmd/streamline_webapp/backend/middleware"
+	"Streamline/cmd/streamline_webapp/backend/models"
+	"Streamline/internal/app"
+	"Streamline/internal/downloader"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// DriveClient returns an HTTP client authorized for Drive, for requests
+// that read or write Drive; main sets it at startup. Without it those
+// requests are refused.
+var DriveClient func(ctx context.Context) (*http.Client, error)
+
+// UploadChunkSize is the chunk size of resumable uploads (0 = default).
+var UploadChunkSize int64
+
+// UploadSessionDir keeps unfinished upload sessions so repeating a request
+// resumes its upload (empty disables).
+var UploadSessionDir string
+
+// DriveRetry governs retries of Drive requests and URL downloads.
+var DriveRetry downloader.RetryPolicy
+
+// DownloadAllowNets are the non-public networks URL downloads may reach.
+// Loopback, link-local and private addresses are refused otherwise, so a
+// request cannot make the server fetch from its own network.
+var DownloadAllowNets []netip.Prefix
+
+// driveService builds a Drive service and returns the client behind it,
+// which resumable uploads also need.
+func driveService(ctx context.Context) (*drive.Service, *http.Client, error) {
+	if DriveClient == nil {
+		return nil, nil, fmt.Errorf("Drive access is not configured on this server")
+	}
+	httpClient, err := DriveClient(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Drive unavailable: %v", err)
+	}
+	svc, err := drive.NewService(ctx, option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, nil, fmt.Errorf("Drive unavailable: %v", err)
+	}
+	return svc, httpClient, nil
+}
+
+// DownloadHandler fetches a URL or magnet link into Drive with a resumable
+// upload, streaming upload progress as server-sent events
+func DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	// Validate request method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get authenticated user
+	userEmail := middleware.GetUserEmail(r)
+	log.Printf("Download requested by: %s", userEmail)
+
+	// Parse JSON request
+	var req models.DownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	svc, httpClient, err := driveService(r.Context())
+	if err != nil {
+		log.Printf("Drive service unavailable: %v", err)
+		sendErrorResponse(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if req.DriveFolder == "" {
+		req.DriveFolder = "root"
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		sendErrorResponse(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Downloads share the extraction registry so /api/cancel stops them too;
+	// a client that disconnects stops its download as well
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	downloadID := fmt.Sprintf("%s_%d", userEmail, getCurrentTimestamp())
+	extractionManager.mu.Lock()
+	extractionManager.extractions[downloadID] = cancel
+	extractionManager.mu.Unlock()
+	defer func() {
+		extractionManager.mu.Lock()
+		delete(extractionManager.extractions, downloadID)
+		extractionManager.mu.Unlock()
+	}()
+
+	log.Printf("Starting download [%s]: %s%s -> %s", downloadID, req.URL, req.Torrent, req.DriveFolder)
+
+	eventChan := make(chan interface{}, 100)
+	go func() {
+		defer close(eventChan)
+		fileID, err := app.RunDownload(ctx, svc, app.DownloadParams{
+			URL:         req.URL,
+			Torrent:     req.Torrent,
+			DriveFolder: req.DriveFolder,
+			Name:        req.Name,
+			HTTP:        downloader.HTTPOptions{PublicOnly: true, AllowNets: DownloadAllowNets},
+			Retry:       DriveRetry,
+			Upload: downloader.UploadOptions{
+				Client:     httpClient,
+				ChunkSize:  UploadChunkSize,
+				SessionDir: UploadSessionDir,
+				Retry:      DriveRetry,
+				OnProgress: func(sent, total int64) {
+					eventChan <- models.NewUploadProgressEvent(sent, total)
+				},
+			},
+		})
+		if err != nil {
+			log.Printf("Download [%s] failed: %v", downloadID, err)
+			eventChan <- models.NewDownloadErrorEvent(err.Error())
+			return
+		}
+		log.Printf("Download [%s] completed: %s", downloadID, fileID)
+		eventChan <- models.NewDownloadCompleteEvent(fileID)
+	}()
+
+	// Setup SSE streaming
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Extraction-ID", downloadID)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for event := range eventChan {
+		data, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("Error encoding event: %v", err)
+			continue
+		}
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+}
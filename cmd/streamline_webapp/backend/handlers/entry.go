@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	streamline_core "Streamline/cmd/streamline_core"
+	"Streamline/cmd/streamline_webapp/backend/middleware"
+	"Streamline/cmd/streamline_webapp/backend/models"
+)
+
+// EntryHandler streams one archive entry's contents to the client without
+// writing anything on the server:
+// GET /api/zip/entry?zip=...&path=...[&disposition=inline]
+// Entries stored uncompressed support Range requests; compressed ones are
+// always sent whole.
+func EntryHandler(w http.ResponseWriter, r *http.Request) {
+	// Validate request method
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get authenticated user
+	userEmail := middleware.GetUserEmail(r)
+	log.Printf("Entry download requested by: %s", userEmail)
+
+	query := r.URL.Query()
+	req := models.EntryRequest{
+		ZipPath:     query.Get("zip"),
+		Path:        query.Get("path"),
+		Disposition: query.Get("disposition"),
+	}
+	if err := req.Validate(); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !checkArchiveSize(w, req.ZipPath) {
+		return
+	}
+
+	entry, rc, err := streamline_core.OpenEntry(req.ZipPath, req.Path, ExtractLimits)
+	if err != nil {
+		sendEntryError(w, req.Path, err)
+		return
+	}
+	defer rc.Close()
+
+	// Compressed entries are peeked at first: that surfaces password and
+	// format errors while an error response can still be sent, and gives
+	// bytes to sniff the type from
+	rs, seekable := rc.(io.ReadSeeker)
+	var br *bufio.Reader
+	var head []byte
+	if !seekable {
+		br = bufio.NewReader(rc)
+		if head, err = br.Peek(512); err != nil && err != io.EOF {
+			sendEntryError(w, req.Path, err)
+			return
+		}
+	}
+
+	name := path.Base(entry.Name)
+	disposition := req.Disposition
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	// Archived HTML previewed inline must not run script on our origin
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+
+	// Stored entries are read in place, so ranges come for free
+	if seekable {
+		http.ServeContent(w, r, name, entry.Modified, rs)
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Accept-Ranges", "none")
+	if entry.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+	}
+	if !entry.Modified.IsZero() {
+		w.Header().Set("Last-Modified", entry.Modified.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// Errors past this point can only cut the response short
+	if n, err := io.Copy(w, br); err != nil {
+		log.Printf("Streaming %s from %s stopped after %d bytes: %v", req.Path, req.ZipPath, n, err)
+	}
+}
+
+// sendEntryError maps a failure to open or read an entry to a status code
+func sendEntryError(w http.ResponseWriter, name string, err error) {
+	var limitErr *streamline_core.LimitError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		sendErrorResponse(w, fmt.Sprintf("Not found: %s", name), http.StatusNotFound)
+	case errors.Is(err, streamline_core.ErrIsDir):
+		sendErrorResponse(w, fmt.Sprintf("%s is a directory", name), http.StatusBadRequest)
+	case errors.Is(err, streamline_core.ErrPasswordRequired), errors.Is(err, streamline_core.ErrBadPassword):
+		sendErrorResponse(w, fmt.Sprintf("%s is encrypted", name), http.StatusForbidden)
+	case errors.As(err, &limitErr):
+		sendErrorResponse(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		log.Printf("Error opening entry %s: %v", name, err)
+		sendErrorResponse(w, fmt.Sprintf("Failed to read entry: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// BundleHandler streams the selected entries of an archive back as a new
+// ZIP or tar.gz assembled on the fly, with nothing written on the server
+func BundleHandler(w http.ResponseWriter, r *http.Request) {
+	// Validate request method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get authenticated user
+	userEmail := middleware.GetUserEmail(r)
+	log.Printf("Bundle requested by: %s", userEmail)
+
+	// Parse JSON request
+	var req models.BundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !checkArchiveSize(w, req.ZipPath) {
+		return
+	}
+
+	filter, err := requestFilter(req.Filter)
+	if err != nil {
+		sendErrorResponse(w, fmt.Sprintf("Invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
+	paths, err := requestPaths(&req.ExtractZipRequest)
+	if err != nil {
+		sendErrorResponse(w, fmt.Sprintf("Invalid rewrite: %v", err), http.StatusBadRequest)
+		return
+	}
+	format, _ := streamline_core.ParsePackFormat(req.Format) // checked by Validate
+
+	archive, err := streamline_core.OpenArchive(req.ZipPath)
+	if err != nil {
+		sendEntryError(w, req.ZipPath, err)
+		return
+	}
+	defer archive.Close()
+
+	// Problems with the selection must be reported before the stream starts;
+	// afterwards the only signal left is a truncated archive
+	selected := make(map[string]bool, len(req.Files))
+	for _, file := range req.Files {
+		selected[file] = true
+	}
+	entries, err := archive.List()
+	if err != nil {
+		sendEntryError(w, req.ZipPath, err)
+		return
+	}
+	found := make(map[string]bool, len(req.Files))
+	for _, e := range entries {
+		if !selected[e.Name] {
+			continue
+		}
+		if e.Encrypted {
+			sendEntryError(w, e.Name, streamline_core.ErrPasswordRequired)
+			return
+		}
+		found[e.Name] = true
+	}
+	for _, file := range req.Files {
+		if !found[file] {
+			sendEntryError(w, file, os.ErrNotExist)
+			return
+		}
+	}
+
+	base := strings.TrimSuffix(filepath.Base(req.ZipPath), filepath.Ext(req.ZipPath))
+	contentType := "application/zip"
+	if format == streamline_core.FormatTarGz {
+		contentType = "application/gzip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": base + "-selection." + string(format),
+	}))
+	w.WriteHeader(http.StatusOK)
+
+	aw, err := streamline_core.NewArchiveWriter(w, format, streamline_core.DefaultCompression)
+	if err != nil {
+		log.Printf("Bundle of %s failed: %v", req.ZipPath, err)
+		return
+	}
+	summary, err := streamline_core.Repack(r.Context(), archive, aw, streamline_core.ExtractOptions{
+		Limits: ExtractLimits,
+		Select: func(e streamline_core.Entry) bool {
+			return selected[e.Name] && filter.Match(e)
+		},
+		Paths: paths,
+	})
+	if err != nil {
+		// Leave the archive unterminated so the client cannot mistake it for
+		// a complete one
+		log.Printf("Bundle of %s aborted: %v", req.ZipPath, err)
+		return
+	}
+	if err := aw.Close(); err != nil {
+		log.Printf("Bundle of %s failed to finish: %v", req.ZipPath, err)
+		return
+	}
+	log.Printf("Bundled %d entries of %s as %s", summary.Extracted, req.ZipPath, format)
+}
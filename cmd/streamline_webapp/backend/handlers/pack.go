@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	streamline_core "Streamline/cmd/streamline_core"
+	"Streamline/cmd/streamline_webapp/backend/middleware"
+	"Streamline/cmd/streamline_webapp/backend/models"
+	"Streamline/internal/downloader"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// PackBaseDir confines the server paths /api/pack reads and writes: sourceDir
+// and outPath are taken relative to it and must stay inside it, symlinks
+// included. Empty refuses server paths, leaving Drive-to-Drive packing.
+var PackBaseDir string
+
+// resolvePackPath maps a request path into PackBaseDir. The path itself
+// need not exist, but its parent must.
+func resolvePackPath(reqPath string) (string, error) {
+	if PackBaseDir == "" {
+		return "", errors.New("server paths are disabled on this server")
+	}
+	base, err := filepath.Abs(PackBaseDir)
+	if err == nil {
+		base, err = filepath.EvalSymlinks(base)
+	}
+	if err != nil {
+		return "", fmt.Errorf("pack base directory unavailable: %v", err)
+	}
+	p := reqPath
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	p = filepath.Clean(p)
+	resolved, err := filepath.EvalSymlinks(p)
+	if errors.Is(err, os.ErrNotExist) {
+		// A new output file: resolve where it will be created instead.
+		var dir string
+		if dir, err = filepath.EvalSymlinks(filepath.Dir(p)); err == nil {
+			resolved = filepath.Join(dir, filepath.Base(p))
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: no such directory or not readable", reqPath)
+	}
+	if resolved != base && !streamline_core.IsPathWithinBase(base, resolved) {
+		return "", fmt.Errorf("%s is outside the pack base directory", reqPath)
+	}
+	return resolved, nil
+}
+
+// PackHandler builds a ZIP or tar.gz from a server directory or a Drive
+// folder and writes it on the server or uploads it to Drive
+func PackHandler(w http.ResponseWriter, r *http.Request) {
+	// Validate request method
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get authenticated user
+	userEmail := middleware.GetUserEmail(r)
+	log.Printf("Pack requested by: %s", userEmail)
+
+	// Parse JSON request
+	var req models.PackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, "Invalid JSON request body", http.StatusBadRequest)
+		return
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		sendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := requestFilter(req.Filter)
+	if err != nil {
+		sendErrorResponse(w, fmt.Sprintf("Invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Server paths are confined to PackBaseDir
+	for _, p := range []*string{&req.SourceDir, &req.OutPath} {
+		if *p == "" {
+			continue
+		}
+		if *p, err = resolvePackPath(*p); err != nil {
+			sendErrorResponse(w, fmt.Sprintf("Invalid path: %v", err), http.StatusForbidden)
+			return
+		}
+	}
+
+	packer := &downloader.Packer{
+		Dir:      req.SourceDir,
+		FolderID: req.FolderID,
+		Level:    streamline_core.DefaultCompression,
+		OutPath:  req.OutPath,
+		Name:     req.Name,
+		Retry:    DriveRetry,
+		Options: streamline_core.ExtractOptions{
+			Limits: ExtractLimits,
+			Select: filter.Match,
+		},
+	}
+	if req.Level != nil {
+		packer.Level = *req.Level
+	}
+	if req.Format != "" {
+		packer.Format, _ = streamline_core.ParsePackFormat(req.Format) // checked by Validate
+	}
+
+	var svc *drive.Service
+	if req.FolderID != "" || req.OutPath == "" {
+		if svc, _, err = driveService(r.Context()); err != nil {
+			log.Printf("Drive service unavailable: %v", err)
+			sendErrorResponse(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	driveFolder := req.DriveFolder
+	if driveFolder == "" {
+		driveFolder = "root"
+	}
+
+	start := time.Now()
+	res, err := packer.Pack(r.Context(), svc, driveFolder)
+	if err != nil {
+		log.Printf("Pack failed: %v", err)
+		sendErrorResponse(w, fmt.Sprintf("Pack failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Packed %d entries (%d bytes) in %s", res.Summary.Extracted, res.Bytes, time.Since(start).Round(time.Millisecond))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := models.PackResponse{
+		FileID:    res.FileID,
+		Path:      res.Path,
+		Bytes:     res.Bytes,
+		Packed:    res.Summary.Extracted,
+		Skipped:   res.Summary.Skipped,
+		Timestamp: getCurrentTimestamp(),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
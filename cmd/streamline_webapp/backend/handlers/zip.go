@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,6 +27,59 @@ var extractionManager = &ExtractionManager{
 	extractions: make(map[string]context.CancelFunc),
 }
 
+// ExtractLimits bounds every extraction started through the API; main sets
+// it from the server configuration at startup.
+var ExtractLimits = streamline_core.DefaultLimits
+
+// MaxArchiveSize rejects archives larger than this many bytes (0 = no limit).
+var MaxArchiveSize int64
+
+// ExtractFilter holds the server-wide filter rules (the same file format the
+// CLI reads); main loads it at startup. Requests may add rules of their own.
+var ExtractFilter *streamline_core.Filter
+
+// requestFilter combines ExtractFilter with the rules sent in a request.
+func requestFilter(rules string) (*streamline_core.Filter, error) {
+	f := &streamline_core.Filter{}
+	f.Merge(ExtractFilter)
+	if rules != "" {
+		extra, err := streamline_core.ParseFilter(strings.NewReader(rules))
+		if err != nil {
+			return nil, err
+		}
+		f.Merge(extra)
+	}
+	return f, nil
+}
+
+// requestPaths builds the path mapping an extraction request asks for
+func requestPaths(req *models.ExtractZipRequest) (streamline_core.PathMapping, error) {
+	paths := streamline_core.PathMapping{StripComponents: req.StripComponents, Flatten: req.Flatten}
+	for _, rule := range req.Rewrites {
+		rw, err := streamline_core.ParsePathRewrite(rule)
+		if err != nil {
+			return paths, err
+		}
+		paths.Rewrites = append(paths.Rewrites, rw)
+	}
+	return paths, nil
+}
+
+// checkArchiveSize enforces MaxArchiveSize, writing the error response
+// itself. It returns false if the request must stop.
+func checkArchiveSize(w http.ResponseWriter, zipPath string) bool {
+	if MaxArchiveSize <= 0 {
+		return true
+	}
+	st, err := os.Stat(zipPath)
+	if err != nil || st.Size() <= MaxArchiveSize {
+		// A missing file is reported by the extraction itself.
+		return true
+	}
+	sendErrorResponse(w, fmt.Sprintf("Archive is %d bytes; the maximum is %d", st.Size(), MaxArchiveSize), http.StatusRequestEntityTooLarge)
+	return false
+}
+
 // ListZipHandler handles requests to list files in a ZIP archive
 func ListZipHandler(w http.ResponseWriter, r *http.Request) {
 	// Validate request method
@@ -48,6 +103,7 @@ func ListZipHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		req.ZipPath = zipPath
+		req.Filter = r.URL.Query().Get("filter")
 	} else {
 		// POST request - use JSON body
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -62,8 +118,18 @@ func ListZipHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkArchiveSize(w, req.ZipPath) {
+		return
+	}
+
+	filter, err := requestFilter(req.Filter)
+	if err != nil {
+		sendErrorResponse(w, fmt.Sprintf("Invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
+
 	// List files in ZIP
-	files, err := streamline_core.ListZipFiles(req.ZipPath)
+	files, err := listFiltered(req.ZipPath, filter)
 	if err != nil {
 		log.Printf("Error listing ZIP files: %v", err)
 		sendErrorResponse(w, fmt.Sprintf("Failed to list ZIP files: %v", err), http.StatusInternalServerError)
@@ -74,14 +140,45 @@ func ListZipHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	response := map[string]interface{}{
-		"files": files,
-		"count": len(files),
+	if err := json.NewEncoder(w).Encode(models.NewListZipResponse(files, req.ZipPath)); err != nil {
+		log.Printf("Error encoding response: %v", err)
 	}
+}
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response: %v", err)
+// listFiltered describes the archive entries filter selects
+func listFiltered(zipPath string, filter *streamline_core.Filter) ([]models.FileInfo, error) {
+	entries, err := streamline_core.ListEntries(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	var files []models.FileInfo
+	for _, e := range entries {
+		if filter.Match(e) {
+			files = append(files, fileInfo(e))
+		}
+	}
+	return files, nil
+}
+
+// fileInfo converts an archive entry to its API representation
+func fileInfo(e streamline_core.Entry) models.FileInfo {
+	info := models.FileInfo{
+		Name:             e.Name,
+		Size:             e.CompressedSize,
+		UncompressedSize: e.Size,
+		IsDirectory:      e.IsDir,
+		Method:           e.Method,
+		Mode:             e.Mode.String(),
+		Encrypted:        e.Encrypted,
+		Comment:          e.Comment,
+	}
+	if e.HasCRC && !e.IsDir {
+		info.CRC32 = fmt.Sprintf("%08x", e.CRC32)
 	}
+	if !e.Modified.IsZero() {
+		info.Modified = e.Modified.Format(time.RFC3339)
+	}
+	return info
 }
 
 // ExtractZipHandler handles requests to extract files from a ZIP archive
@@ -109,6 +206,22 @@ func ExtractZipHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !checkArchiveSize(w, req.ZipPath) {
+		return
+	}
+
+	filter, err := requestFilter(req.Filter)
+	if err != nil {
+		sendErrorResponse(w, fmt.Sprintf("Invalid filter: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	paths, err := requestPaths(&req)
+	if err != nil {
+		sendErrorResponse(w, fmt.Sprintf("Invalid rewrite: %v", err), http.StatusBadRequest)
+		return
+	}
+
 	// Set default output directory if not provided
 	if req.OutDir == "" {
 		req.OutDir = "./extracted_files"
@@ -141,13 +254,38 @@ func ExtractZipHandler(w http.ResponseWriter, r *http.Request) {
 	go func() {
 		defer close(logChan)
 
-		// Perform extraction
-		err := streamline_core.ExtractSelectedFiles(ctx, req.ZipPath, req.OutDir, req.Files, logChan)
+		// Perform extraction (the policy was checked by Validate)
+		policy, _ := streamline_core.ParseConflictPolicy(req.ConflictPolicy)
+		summary, err := streamline_core.ExtractSelectedFilesWithOptions(ctx, req.ZipPath, req.OutDir, req.Files, logChan,
+			streamline_core.ExtractOptions{
+				Limits:   ExtractLimits,
+				Conflict: policy,
+				Select:   filter.Match,
+				Paths:    paths,
+			})
 		if err != nil {
-			logChan <- fmt.Sprintf("ERROR: %v", err)
+			// Typed failures (limits, passwords) carry a code the client can act on.
+			event := models.NewExtractionErrorEvent(err.Error(), streamline_core.ErrorCode(err))
+			if data, jerr := json.Marshal(event); jerr == nil {
+				logChan <- string(data)
+			} else {
+				logChan <- fmt.Sprintf("ERROR: %v", err)
+			}
 			log.Printf("Extraction [%s] failed: %v", extractionID, err)
 		} else {
-			log.Printf("Extraction [%s] completed successfully", extractionID)
+			event := models.NewExtractionCompleteEvent(summary.Extracted, summary.Skipped, nil)
+			for _, c := range summary.Conflicts {
+				event.Conflicts = append(event.Conflicts, models.ConflictDecision{
+					File:      c.Name,
+					Policy:    string(c.Policy),
+					Action:    string(c.Action),
+					RenamedTo: c.RenamedTo,
+				})
+			}
+			if data, jerr := json.Marshal(event); jerr == nil {
+				logChan <- string(data)
+			}
+			log.Printf("Extraction [%s] completed successfully (%d conflicts)", extractionID, len(summary.Conflicts))
 		}
 	}()
 
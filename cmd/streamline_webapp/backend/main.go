@@ -12,6 +12,7 @@ import (
 
 	"Streamline/cmd/streamline_webapp/backend/handlers"
 	"Streamline/cmd/streamline_webapp/backend/middleware"
+	"Streamline/internal/auth"
 )
 
 func main() {
@@ -33,6 +34,23 @@ func main() {
 	// Log configuration (without exposing secrets)
 	cfg.LogConfig()
 
+	// Apply size and zip-bomb guardrails to API extractions
+	handlers.ExtractLimits = cfg.ExtractLimits()
+	handlers.MaxArchiveSize = cfg.MaxFileSize
+
+	// Apply the shared filter rules to every listing and extraction
+	if handlers.ExtractFilter, err = cfg.Filter(); err != nil {
+		log.Fatalf("Failed to load filter file: %v", err)
+	}
+
+	// Drive-backed endpoints use the same stored credentials as the CLI
+	handlers.DriveClient = auth.GetClient
+	handlers.UploadChunkSize = int64(cfg.UploadChunkMB) << 20
+	handlers.UploadSessionDir = cfg.UploadDir
+	handlers.DriveRetry = cfg.RetryPolicy()
+	handlers.DownloadAllowNets, _ = cfg.AllowNets() // checked by Validate
+	handlers.PackBaseDir = cfg.PackBaseDir
+
 	// Create HTTP server
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -92,6 +110,29 @@ func setupRoutes(cfg *Config) http.Handler {
 			middleware.OptionalAuthMiddleware(
 				http.HandlerFunc(handlers.ExtractZipHandler)))))
 
+	mux.HandleFunc("/api/zip/entry", wrapHandler(
+		middleware.CORS(cfg.AllowedOrigins)(
+			middleware.OptionalAuthMiddleware(
+				http.HandlerFunc(handlers.EntryHandler)))))
+
+	mux.HandleFunc("/api/zip/bundle", wrapHandler(
+		middleware.CORS(cfg.AllowedOrigins)(
+			middleware.OptionalAuthMiddleware(
+				http.HandlerFunc(handlers.BundleHandler)))))
+
+	// Packing reads server files and writes into the server's Drive, so it
+	// needs a signed-in user
+	mux.HandleFunc("/api/pack", wrapHandler(
+		middleware.CORS(cfg.AllowedOrigins)(
+			middleware.AuthMiddleware(
+				http.HandlerFunc(handlers.PackHandler)))))
+
+	// Downloads write into the server's Drive, so they need a signed-in user
+	mux.HandleFunc("/api/download", wrapHandler(
+		middleware.CORS(cfg.AllowedOrigins)(
+			middleware.AuthMiddleware(
+				http.HandlerFunc(handlers.DownloadHandler)))))
+
 	mux.HandleFunc("/api/cancel", wrapHandler(
 		middleware.CORS(cfg.AllowedOrigins)(
 			middleware.OptionalAuthMiddleware(
@@ -1,10 +1,16 @@
 package models
 
-import "time"
+import (
+	"net/url"
+	"strings"
+	"time"
+)
 
 // ListZipRequest represents a request to list files in a ZIP archive
 type ListZipRequest struct {
 	ZipPath string `json:"zip" validate:"required"`
+	// Filter holds extra filter rules, one per line, in .streamlineignore syntax
+	Filter string `json:"filter,omitempty"`
 }
 
 // Validate checks if the ListZipRequest is valid
@@ -17,6 +23,10 @@ func (r *ListZipRequest) Validate() error {
 		return NewValidationError("zip_path_too_long", "ZIP file path is too long (max 1000 characters)")
 	}
 
+	if len(r.Filter) > maxFilterLength {
+		return NewValidationError("filter_too_long", "Filter rules are too long (max 64 KB)")
+	}
+
 	return nil
 }
 
@@ -25,6 +35,29 @@ type ExtractZipRequest struct {
 	ZipPath string   `json:"zip" validate:"required"`
 	Files   []string `json:"files" validate:"required,min=1"`
 	OutDir  string   `json:"outDir,omitempty"`
+	// ConflictPolicy is one of overwrite (default), skip, rename, newer, fail
+	ConflictPolicy string `json:"conflictPolicy,omitempty"`
+	// Filter holds extra filter rules, one per line, in .streamlineignore syntax
+	Filter string `json:"filter,omitempty"`
+	// StripComponents removes leading directories from entry paths
+	StripComponents int `json:"stripComponents,omitempty"`
+	// Rewrites replace leading directories, each written "from=to"
+	Rewrites []string `json:"rewrites,omitempty"`
+	// Flatten extracts every file directly into OutDir
+	Flatten bool `json:"flatten,omitempty"`
+}
+
+// maxFilterLength bounds the filter rules a request may carry
+const maxFilterLength = 64 * 1024
+
+// conflictPolicies are the accepted ExtractZipRequest.ConflictPolicy values
+var conflictPolicies = map[string]bool{
+	"":          true,
+	"overwrite": true,
+	"skip":      true,
+	"rename":    true,
+	"newer":     true,
+	"fail":      true,
 }
 
 // Validate checks if the ExtractZipRequest is valid
@@ -37,6 +70,10 @@ func (r *ExtractZipRequest) Validate() error {
 		return NewValidationError("zip_path_too_long", "ZIP file path is too long (max 1000 characters)")
 	}
 
+	if len(r.Filter) > maxFilterLength {
+		return NewValidationError("filter_too_long", "Filter rules are too long (max 64 KB)")
+	}
+
 	if len(r.Files) == 0 {
 		return NewValidationError("files_required", "At least one file must be selected for extraction")
 	}
@@ -45,6 +82,18 @@ func (r *ExtractZipRequest) Validate() error {
 		return NewValidationError("too_many_files", "Too many files selected (max 10000)")
 	}
 
+	if r.StripComponents < 0 || r.StripComponents > 64 {
+		return NewValidationError("invalid_strip_components", "stripComponents must be between 0 and 64")
+	}
+
+	if len(r.Rewrites) > 100 {
+		return NewValidationError("too_many_rewrites", "Too many path rewrites (max 100)")
+	}
+
+	if !conflictPolicies[r.ConflictPolicy] {
+		return NewValidationError("invalid_conflict_policy", "Conflict policy must be overwrite, skip, rename, newer or fail (got %q)", r.ConflictPolicy)
+	}
+
 	// Validate each file path
 	for i, file := range r.Files {
 		if file == "" {
@@ -64,6 +113,160 @@ func (r *ExtractZipRequest) Validate() error {
 	return nil
 }
 
+// BundleRequest asks for selected entries repacked into a new archive that
+// is streamed back instead of extracted. OutDir and ConflictPolicy do not
+// apply; Filter and the path options shape the new archive
+type BundleRequest struct {
+	ExtractZipRequest
+	// Format is zip (default) or tar.gz
+	Format string `json:"format,omitempty"`
+}
+
+// Validate checks if the BundleRequest is valid
+func (r *BundleRequest) Validate() error {
+	if err := r.ExtractZipRequest.Validate(); err != nil {
+		return err
+	}
+
+	if r.Format != "" && r.Format != "zip" && r.Format != "tar.gz" && r.Format != "tgz" {
+		return NewValidationError("invalid_format", "Format must be zip or tar.gz (got %q)", r.Format)
+	}
+
+	return nil
+}
+
+// DownloadRequest asks for a URL or magnet link to be fetched and uploaded
+// into Drive
+type DownloadRequest struct {
+	URL     string `json:"url,omitempty"`
+	Torrent string `json:"torrent,omitempty"` // magnet link
+	// DriveFolder receives the upload (default root)
+	DriveFolder string `json:"driveFolder,omitempty"`
+	// Name of the uploaded file (default: taken from the source)
+	Name string `json:"name,omitempty"`
+}
+
+// Validate checks if the DownloadRequest is valid
+func (r *DownloadRequest) Validate() error {
+	if (r.URL == "") == (r.Torrent == "") {
+		return NewValidationError("source_required", "Exactly one of url or torrent is required")
+	}
+
+	if len(r.URL) > 4096 || len(r.Torrent) > 4096 {
+		return NewValidationError("source_too_long", "Source is too long (max 4096 characters)")
+	}
+
+	if r.URL != "" {
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return NewValidationError("invalid_url", "URL must be an absolute http or https URL")
+		}
+	}
+
+	if r.Torrent != "" && !strings.HasPrefix(r.Torrent, "magnet:") {
+		return NewValidationError("invalid_torrent", "Torrent must be a magnet link")
+	}
+
+	if len(r.DriveFolder) > 200 {
+		return NewValidationError("folder_id_too_long", "Drive folder ID is too long (max 200 characters)")
+	}
+
+	if len(r.Name) > 255 || containsPathTraversal(r.Name) || strings.ContainsAny(r.Name, `/\`) {
+		return NewValidationError("invalid_name", "Name must be a plain file name (got %q)", r.Name)
+	}
+
+	return nil
+}
+
+// PackRequest asks for a new archive built from a server directory or a
+// Drive folder, written to OutPath on the server or uploaded to Drive
+type PackRequest struct {
+	SourceDir string `json:"sourceDir,omitempty"`
+	FolderID  string `json:"folderId,omitempty"`
+	// Format is zip or tar.gz; by default it follows the OutPath or Name extension
+	Format string `json:"format,omitempty"`
+	// Level is the compression level, 0 (store) to 9; omitted means the default
+	Level *int `json:"level,omitempty"`
+	// OutPath writes the archive on the server instead of uploading it
+	OutPath string `json:"outPath,omitempty"`
+	// DriveFolder receives the upload (default root)
+	DriveFolder string `json:"driveFolder,omitempty"`
+	// Name of the uploaded file (default: the source's name)
+	Name string `json:"name,omitempty"`
+	// Filter holds extra filter rules, one per line, in .streamlineignore syntax
+	Filter string `json:"filter,omitempty"`
+}
+
+// Validate checks if the PackRequest is valid
+func (r *PackRequest) Validate() error {
+	if (r.SourceDir == "") == (r.FolderID == "") {
+		return NewValidationError("source_required", "Exactly one of sourceDir or folderId is required")
+	}
+
+	if len(r.SourceDir) > 1000 || len(r.OutPath) > 1000 {
+		return NewValidationError("path_too_long", "Path is too long (max 1000 characters)")
+	}
+
+	if len(r.FolderID) > 200 || len(r.DriveFolder) > 200 {
+		return NewValidationError("folder_id_too_long", "Drive folder ID is too long (max 200 characters)")
+	}
+
+	if len(r.Name) > 255 || containsPathTraversal(r.Name) || strings.ContainsAny(r.Name, `/\`) {
+		return NewValidationError("invalid_name", "Name must be a plain file name (got %q)", r.Name)
+	}
+
+	if r.Format != "" && r.Format != "zip" && r.Format != "tar.gz" && r.Format != "tgz" {
+		return NewValidationError("invalid_format", "Format must be zip or tar.gz (got %q)", r.Format)
+	}
+
+	if r.Level != nil && (*r.Level < -1 || *r.Level > 9) {
+		return NewValidationError("invalid_level", "Compression level must be between 0 and 9, or -1 for the default")
+	}
+
+	if len(r.Filter) > maxFilterLength {
+		return NewValidationError("filter_too_long", "Filter rules are too long (max 64 KB)")
+	}
+
+	return nil
+}
+
+// EntryRequest identifies one archive entry to stream to the client
+type EntryRequest struct {
+	ZipPath string `json:"zip" validate:"required"`
+	Path    string `json:"path" validate:"required"`
+	// Disposition is attachment (default, a download) or inline (a preview)
+	Disposition string `json:"disposition,omitempty"`
+}
+
+// Validate checks if the EntryRequest is valid
+func (r *EntryRequest) Validate() error {
+	if r.ZipPath == "" {
+		return NewValidationError("zip_path_required", "ZIP file path is required")
+	}
+
+	if len(r.ZipPath) > 1000 {
+		return NewValidationError("zip_path_too_long", "ZIP file path is too long (max 1000 characters)")
+	}
+
+	if r.Path == "" {
+		return NewValidationError("entry_path_required", "Entry path is required")
+	}
+
+	if len(r.Path) > 1000 {
+		return NewValidationError("file_path_too_long", "File path is too long (max 1000 characters)")
+	}
+
+	if containsPathTraversal(r.Path) {
+		return NewValidationError("invalid_file_path", "File path contains invalid characters (%s)", r.Path)
+	}
+
+	if r.Disposition != "" && r.Disposition != "attachment" && r.Disposition != "inline" {
+		return NewValidationError("invalid_disposition", "Disposition must be attachment or inline (got %q)", r.Disposition)
+	}
+
+	return nil
+}
+
 // CancelRequest represents a request to cancel an ongoing extraction
 // This request has no body, but we define it for consistency
 type CancelRequest struct {
@@ -90,9 +293,15 @@ func (r *HealthCheckRequest) Validate() error {
 // FileInfo represents information about a file in a ZIP archive
 type FileInfo struct {
 	Name             string `json:"name"`
-	Size             int64  `json:"size"`
+	Size             int64  `json:"size"` // compressed size in the archive
 	UncompressedSize int64  `json:"uncompressedSize"`
 	IsDirectory      bool   `json:"isDirectory"`
+	Method           string `json:"method,omitempty"`
+	CRC32            string `json:"crc32,omitempty"`    // 8 hex digits, when recorded
+	Modified         string `json:"modified,omitempty"` // RFC 3339
+	Mode             string `json:"mode"`
+	Encrypted        bool   `json:"encrypted"`
+	Comment          string `json:"comment,omitempty"`
 }
 
 // ExtractionProgress represents the progress of an extraction operation
@@ -108,6 +317,7 @@ type ExtractionProgress struct {
 type ExtractionError struct {
 	File   string `json:"file"`
 	Reason string `json:"reason"`
+	Code   string `json:"code,omitempty"`
 }
 
 // Helper functions
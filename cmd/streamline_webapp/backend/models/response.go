@@ -44,16 +44,16 @@ func NewErrorResponse(message string, code string, status int) *ErrorResponse {
 
 // ListZipResponse represents the response for listing files in a ZIP
 type ListZipResponse struct {
-	Files      []string `json:"files"`
-	Count      int      `json:"count"`
-	ZipPath    string   `json:"zipPath"`
-	Timestamp  int64    `json:"timestamp"`
+	Files      []FileInfo `json:"files"`
+	Count      int        `json:"count"`
+	ZipPath    string     `json:"zipPath"`
+	Timestamp  int64      `json:"timestamp"`
 }
 
 // NewListZipResponse creates a new ListZipResponse
-func NewListZipResponse(files []string, zipPath string) *ListZipResponse {
+func NewListZipResponse(files []FileInfo, zipPath string) *ListZipResponse {
 	if files == nil {
-		files = []string{}
+		files = []FileInfo{}
 	}
 	return &ListZipResponse{
 		Files:     files,
@@ -81,6 +81,16 @@ func NewExtractZipResponse(extractionID string) *ExtractZipResponse {
 	}
 }
 
+// PackResponse reports the archive a pack request produced
+type PackResponse struct {
+	FileID    string `json:"fileId,omitempty"`
+	Path      string `json:"path,omitempty"`
+	Bytes     int64  `json:"bytes"`
+	Packed    int    `json:"packed"`
+	Skipped   int    `json:"skipped"`
+	Timestamp int64  `json:"timestamp"`
+}
+
 // CancelResponse represents the response for cancellation requests
 type CancelResponse struct {
 	Status       string `json:"status"`
@@ -186,10 +196,19 @@ type ExtractionCompleteEvent struct {
 	FilesExtracted   int                `json:"filesExtracted"`
 	FilesSkipped     int                `json:"filesSkipped"`
 	Errors           []ExtractionError  `json:"errors,omitempty"`
+	Conflicts        []ConflictDecision `json:"conflicts,omitempty"`
 	Message          string             `json:"message"`
 	Timestamp        int64              `json:"timestamp"`
 }
 
+// ConflictDecision records what was done with a file that already existed
+type ConflictDecision struct {
+	File      string `json:"file"`
+	Policy    string `json:"policy"`
+	Action    string `json:"action"`
+	RenamedTo string `json:"renamedTo,omitempty"`
+}
+
 // NewExtractionCompleteEvent creates a new ExtractionCompleteEvent
 func NewExtractionCompleteEvent(filesExtracted, filesSkipped int, errors []ExtractionError) *ExtractionCompleteEvent {
 	return &ExtractionCompleteEvent{
@@ -208,21 +227,78 @@ type ExtractionErrorEvent struct {
 	Type      string `json:"type"`
 	Status    string `json:"status"`
 	Error     string `json:"error"`
+	Code      string `json:"code,omitempty"`
 	Message   string `json:"message"`
 	Timestamp int64  `json:"timestamp"`
 }
 
-// NewExtractionErrorEvent creates a new ExtractionErrorEvent
-func NewExtractionErrorEvent(errorMsg string) *ExtractionErrorEvent {
+// NewExtractionErrorEvent creates a new ExtractionErrorEvent. code is a
+// machine-readable reason such as "entry_bytes_exceeded", or empty.
+func NewExtractionErrorEvent(errorMsg string, code string) *ExtractionErrorEvent {
 	return &ExtractionErrorEvent{
 		Type:      "error",
 		Status:    "failed",
 		Error:     errorMsg,
+		Code:      code,
 		Message:   "Extraction failed",
 		Timestamp: time.Now().UnixMilli(),
 	}
 }
 
+// UploadProgressEvent reports how much of an upload Drive has stored
+type UploadProgressEvent struct {
+	Type      string `json:"type"`
+	Sent      int64  `json:"sent"`
+	Total     int64  `json:"total"`             // -1 while unknown
+	Percent   int    `json:"percent,omitempty"` // only when Total is known
+	Timestamp int64  `json:"timestamp"`
+}
+
+// NewUploadProgressEvent creates a new UploadProgressEvent
+func NewUploadProgressEvent(sent, total int64) *UploadProgressEvent {
+	event := &UploadProgressEvent{
+		Type:      "progress",
+		Sent:      sent,
+		Total:     total,
+		Timestamp: time.Now().UnixMilli(),
+	}
+	if total > 0 {
+		event.Percent = int(sent * 100 / total)
+	}
+	return event
+}
+
+// DownloadCompleteEvent represents a finished download for SSE
+type DownloadCompleteEvent struct {
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	FileID    string `json:"fileId"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// NewDownloadCompleteEvent creates a new DownloadCompleteEvent
+func NewDownloadCompleteEvent(fileID string) *DownloadCompleteEvent {
+	return &DownloadCompleteEvent{
+		Type:      "complete",
+		Status:    "finished",
+		FileID:    fileID,
+		Message:   "Upload completed",
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
+// NewDownloadErrorEvent creates an error event for a failed download
+func NewDownloadErrorEvent(errorMsg string) *ExtractionErrorEvent {
+	return &ExtractionErrorEvent{
+		Type:      "error",
+		Status:    "failed",
+		Error:     errorMsg,
+		Message:   "Download failed",
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
 // PaginatedResponse represents a paginated response
 type PaginatedResponse struct {
 	Data      interface{} `json:"data"`
@@ -259,6 +259,8 @@ function Panel1Content({
   onNext,
   isActive,
   loading,
+  entryUrl,
+  onDownload,
 }) {
   const allSelected = selectedFiles.length === fileList.length;
 
@@ -335,6 +337,22 @@ function Panel1Content({
               >
                 {file}
               </span>
+              {entryUrl && !file.endsWith("/") && (
+                <a
+                  href={entryUrl(file)}
+                  onClick={(e) => e.stopPropagation()}
+                  title="Download this file"
+                  style={{
+                    marginLeft: "auto",
+                    fontFamily: "'Press Start 2P', monospace",
+                    fontSize: "7px",
+                    color: checked ? "#fff" : "#000",
+                    textDecoration: "none",
+                  }}
+                >
+                  ↓
+                </a>
+              )}
             </div>
           );
         })}
@@ -361,6 +379,17 @@ function Panel1Content({
           >
             {allSelected ? "☐ DESELECT" : "■ ALL"}
           </button>
+          {onDownload && (
+            <button
+              className="retro-btn"
+              onClick={onDownload}
+              disabled={selectedFiles.length === 0 || loading}
+              title="Download the selected files as a new ZIP"
+              style={{ fontSize: "7px", padding: "8px 10px" }}
+            >
+              ↓ ZIP
+            </button>
+          )}
           <button
             className="retro-btn primary"
             onClick={onNext}
@@ -756,7 +785,8 @@ export default function ZipExtractor({
       }
 
       const data = await response.json();
-      setFileList(data.files || []);
+      // Entries arrive as FileInfo objects; the picker works on names
+      setFileList((data.files || []).map((f) => f.name));
       setSelectedFiles([]);
       addLog(`✓ Loaded ${data.count} files from ZIP`);
     } catch (err) {
@@ -769,6 +799,42 @@ export default function ZipExtractor({
   };
 
   // ── Step 1 → 2: Start Extraction ───────────────────────────────────────
+  // Repack the selection on the server and save it, without extracting
+  const downloadSelection = async () => {
+    if (selectedFiles.length === 0) return;
+    setError(null);
+    try {
+      const response = await fetch(`${apiBase}/api/zip/bundle`, {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          Authorization: `Bearer ${token}`,
+        },
+        body: JSON.stringify({
+          zip: zipPath,
+          files: selectedFiles,
+          format: "zip",
+        }),
+      });
+
+      if (!response.ok) {
+        const errorData = await response.json();
+        throw new Error(errorData.message || "Download failed");
+      }
+
+      const disposition = response.headers.get("Content-Disposition") || "";
+      const match = disposition.match(/filename="?([^";]+)"?/);
+      const url = URL.createObjectURL(await response.blob());
+      const link = document.createElement("a");
+      link.href = url;
+      link.download = match ? match[1] : "selection.zip";
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      setError(err.message);
+    }
+  };
+
   const startExtraction = async () => {
     if (selectedFiles.length === 0) return;
     setStep(2);
@@ -822,6 +888,31 @@ export default function ZipExtractor({
         for (const line of lines) {
           if (line.startsWith("data: ")) {
             const logMessage = line.slice(6);
+
+            // Failures and the final summary arrive as JSON events
+            if (logMessage.startsWith("{")) {
+              let event = null;
+              try {
+                event = JSON.parse(logMessage);
+              } catch {
+                // Not an event; log it as-is below
+              }
+              if (event && event.type === "error") {
+                throw new Error(
+                  event.code ? `${event.error} [${event.code}]` : event.error,
+                );
+              }
+              if (event && event.type === "complete") {
+                for (const c of event.conflicts || []) {
+                  addLog(
+                    c.renamedTo
+                      ? `! ${c.file} already existed; saved as ${c.renamedTo}`
+                      : `! ${c.file} already existed; ${c.action}`,
+                  );
+                }
+                continue;
+              }
+            }
             addLog(logMessage);
 
             // Update progress based on log message
@@ -1032,6 +1123,10 @@ export default function ZipExtractor({
                 onNext={startExtraction}
                 isActive={step === 1}
                 loading={loading}
+                onDownload={downloadSelection}
+                entryUrl={(file) =>
+                  `${apiBase}/api/zip/entry?zip=${encodeURIComponent(zipPath)}&path=${encodeURIComponent(file)}`
+                }
               />
             </PanelWrapper>
           </SwiperSlide>
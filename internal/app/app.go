@@ -1,6 +1,7 @@
 package app
 
 import (
+	streamline_core "Streamline/cmd/streamline_core"
 	"Streamline/internal/downloader"
 	"context"
 	"fmt"
@@ -13,13 +14,44 @@ type DownloadParams struct {
     Torrent    string
     FileID     string
     OutDir     string
-    DriveFolder string
+    DriveFolder     string
+    Name            string                      // optional: file name for URL and torrent uploads
+    Upload          downloader.UploadOptions    // chunking, resume and progress for uploads
+    Segments        int                         // parallel range requests for URLs (0 = default)
+    SegmentSize     int64                       // bytes per range request (0 = default)
+    HTTP            downloader.HTTPOptions      // headers, credentials, proxy and TLS for URLs
+    Retry           downloader.RetryPolicy      // for URL probes and segments and Drive reads (zero = default)
+    Checksum        downloader.Checksum         // expected digest of the download (zero = none)
+    ChecksumSidecar bool                        // look for a .sha256 or SHA256SUMS beside the URL
+    Cache           downloader.ChunkCache       // chunk cache for Drive extraction (nil = in-memory LRU)
+    Password        string                      // for encrypted archives extracted from Drive
+    Paths           streamline_core.PathMapping // strip, rewrite or flatten entry paths of Drive extractions
+    ToDrive         bool                        // extract FileID into DriveFolder instead of OutDir
+    Limits          streamline_core.Limits      // size and ratio guards for Drive extractions (zero = defaults)
 }
 
 func RunDownload(ctx context.Context, svc *drive.Service, p DownloadParams) (string, error) {
     d := downloader.NewDownloaderFromFlags(p.URL, p.Torrent, p.FileID, p.OutDir)
+    if d == nil && p.FileID != "" && p.ToDrive {
+        // Extracting into Drive needs no local output directory.
+        d = &downloader.DriveExtractor{FileID: p.FileID}
+    }
     if d == nil {
         return "", fmt.Errorf("invalid params")
     }
+    switch d := d.(type) {
+    case *downloader.URLDownloader:
+        d.Name, d.Upload = p.Name, p.Upload
+        d.Segments, d.SegmentSize = p.Segments, p.SegmentSize
+        d.HTTP, d.Retry = p.HTTP, p.Retry
+        d.Checksum, d.ChecksumSidecar = p.Checksum, p.ChecksumSidecar
+    case *downloader.TorrentDownloader:
+        d.Name, d.Upload = p.Name, p.Upload
+        d.Checksum = p.Checksum
+    case *downloader.DriveExtractor:
+        d.Cache, d.Password = p.Cache, p.Password
+        d.Paths, d.ToDrive = p.Paths, p.ToDrive
+        d.Limits, d.Retry = p.Limits, p.Retry
+    }
     return d.DownloadAndUpload(ctx, svc, p.DriveFolder)
 }
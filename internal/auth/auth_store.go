@@ -223,7 +223,9 @@ func GetClient(ctx context.Context) (*http.Client, error) {
         return nil, fmt.Errorf("read credentials: %w", err)
     }
 
-    config, err := google.ConfigFromJSON(b, drive.DriveReadonlyScope)
+    // Uploads (URL downloads, -pack) write into arbitrary folders, which the
+    // narrower drive.file scope does not allow.
+    config, err := google.ConfigFromJSON(b, drive.DriveScope)
     if err != nil {
         return nil, fmt.Errorf("parse credentials: %w", err)
     }
@@ -9,6 +9,12 @@ type Config struct {
     ClientSecret string
     RedirectURI  string
     LogDir       string
+    CacheDir     string
+    ZipPassword  string
+    FilterFile   string
+    UploadDir    string
+    BearerToken  string
+    NetrcFile    string
 }
 
 func Load() *Config {
@@ -17,5 +23,11 @@ func Load() *Config {
         ClientSecret: os.Getenv("STREAMLINE_CLIENT_SECRET"),
         RedirectURI:  os.Getenv("STREAMLINE_REDIRECT_URI"),
         LogDir:       "logs",
+        CacheDir:     os.Getenv("STREAMLINE_CACHE_DIR"),
+        ZipPassword:  os.Getenv("STREAMLINE_ZIP_PASSWORD"),
+        FilterFile:   os.Getenv("STREAMLINE_FILTER_FILE"),
+        UploadDir:    os.Getenv("STREAMLINE_UPLOAD_DIR"),
+        BearerToken:  os.Getenv("STREAMLINE_BEARER_TOKEN"),
+        NetrcFile:    os.Getenv("NETRC"),
     }
 }
@@ -0,0 +1,191 @@
+package downloader
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ChunkCache stores fixed-size chunks of a remote file. Keys identify the
+// file revision (see ChunkCacheKey) and idx is the chunk index within it.
+type ChunkCache interface {
+    Get(key string, idx int64) ([]byte, bool)
+    Put(key string, idx int64, data []byte)
+}
+
+// ChunkCacheKey derives a cache key from the Drive file ID and the revision
+// metadata, so a re-uploaded file never serves stale chunks.
+func ChunkCacheKey(fileID, md5Checksum, modifiedTime string) string {
+    sum := sha256.Sum256([]byte(fileID + "\x00" + md5Checksum + "\x00" + modifiedTime))
+    return hex.EncodeToString(sum[:16])
+}
+
+type chunkKey struct {
+    key string
+    idx int64
+}
+
+type lruItem struct {
+    k    chunkKey
+    data []byte
+}
+
+// MemoryCache is an in-memory LRU bounded by total bytes held.
+type MemoryCache struct {
+    maxBytes int64
+
+    mu    sync.Mutex
+    used  int64
+    order *list.List // front = most recently used
+    items map[chunkKey]*list.Element
+}
+
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+    return &MemoryCache{
+        maxBytes: maxBytes,
+        order:    list.New(),
+        items:    make(map[chunkKey]*list.Element),
+    }
+}
+
+func (c *MemoryCache) Get(key string, idx int64) ([]byte, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    el, ok := c.items[chunkKey{key, idx}]
+    if !ok {
+        return nil, false
+    }
+    c.order.MoveToFront(el)
+    return el.Value.(*lruItem).data, true
+}
+
+func (c *MemoryCache) Put(key string, idx int64, data []byte) {
+    if int64(len(data)) > c.maxBytes {
+        return
+    }
+    k := chunkKey{key, idx}
+
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if el, ok := c.items[k]; ok {
+        item := el.Value.(*lruItem)
+        c.used += int64(len(data)) - int64(len(item.data))
+        item.data = data
+        c.order.MoveToFront(el)
+    } else {
+        c.items[k] = c.order.PushFront(&lruItem{k: k, data: data})
+        c.used += int64(len(data))
+    }
+    for c.used > c.maxBytes {
+        el := c.order.Back()
+        if el == nil {
+            break
+        }
+        item := el.Value.(*lruItem)
+        c.order.Remove(el)
+        delete(c.items, item.k)
+        c.used -= int64(len(item.data))
+    }
+}
+
+// DiskCache persists chunks under dir/<key>/<idx>.chunk so they survive
+// restarts. Writes go through a temp file and rename, so a crash never
+// leaves a torn chunk behind.
+type DiskCache struct {
+    dir string
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil {
+        return nil, fmt.Errorf("create cache dir: %w", err)
+    }
+    return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) chunkPath(key string, idx int64) string {
+    return filepath.Join(c.dir, key, fmt.Sprintf("%d.chunk", idx))
+}
+
+func (c *DiskCache) Get(key string, idx int64) ([]byte, bool) {
+    data, err := os.ReadFile(c.chunkPath(key, idx))
+    if err != nil {
+        return nil, false
+    }
+    return data, true
+}
+
+func (c *DiskCache) Put(key string, idx int64, data []byte) {
+    path := c.chunkPath(key, idx)
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return
+    }
+    tmp, err := os.CreateTemp(filepath.Dir(path), ".chunk-*")
+    if err != nil {
+        return
+    }
+    _, werr := tmp.Write(data)
+    cerr := tmp.Close()
+    if werr != nil || cerr != nil {
+        os.Remove(tmp.Name())
+        return
+    }
+    if err := os.Rename(tmp.Name(), path); err != nil {
+        os.Remove(tmp.Name())
+    }
+}
+
+// Purge removes every cached chunk for key.
+func (c *DiskCache) Purge(key string) error {
+    return os.RemoveAll(filepath.Join(c.dir, key))
+}
+
+// TieredCache checks a fast tier before a slow one and promotes slow-tier
+// hits into the fast tier. Either tier may be nil.
+type TieredCache struct {
+    Fast ChunkCache
+    Slow ChunkCache
+}
+
+func (t *TieredCache) Get(key string, idx int64) ([]byte, bool) {
+    if t.Fast != nil {
+        if data, ok := t.Fast.Get(key, idx); ok {
+            return data, true
+        }
+    }
+    if t.Slow != nil {
+        if data, ok := t.Slow.Get(key, idx); ok {
+            if t.Fast != nil {
+                t.Fast.Put(key, idx, data)
+            }
+            return data, true
+        }
+    }
+    return nil, false
+}
+
+func (t *TieredCache) Put(key string, idx int64, data []byte) {
+    if t.Fast != nil {
+        t.Fast.Put(key, idx, data)
+    }
+    if t.Slow != nil {
+        t.Slow.Put(key, idx, data)
+    }
+}
+
+// NewChunkCache builds the default cache layout: a bounded in-memory LRU,
+// backed by a disk tier when diskDir is set.
+func NewChunkCache(memBytes int64, diskDir string) (ChunkCache, error) {
+    mem := NewMemoryCache(memBytes)
+    if diskDir == "" {
+        return mem, nil
+    }
+    disk, err := NewDiskCache(diskDir)
+    if err != nil {
+        return nil, err
+    }
+    return &TieredCache{Fast: mem, Slow: disk}, nil
+}
@@ -0,0 +1,298 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// ChecksumProperty is the appProperties key downloads record their SHA-256
+// under, as lower-case hex.
+const ChecksumProperty = "sha256"
+
+// ErrChecksumMismatch matches every ChecksumError with errors.Is.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumError reports that a download's content did not hash to what was
+// expected. The uploaded file has been deleted from Drive unless FileID is
+// still set.
+type ChecksumError struct {
+    Expected Checksum
+    Actual   string // hex digest of what was downloaded
+    Sidecar  string // the checksum file Expected was read from, if any
+    FileID   string // the upload, if deleting it failed too
+}
+
+func (e *ChecksumError) Error() string {
+    msg := fmt.Sprintf("%s: expected %s, got %s:%s", ErrChecksumMismatch, e.Expected, e.Expected.Algorithm, e.Actual)
+    if e.Sidecar != "" {
+        msg += " (expected digest from " + e.Sidecar + ")"
+    }
+    if e.FileID != "" {
+        msg += "; the upload " + e.FileID + " could not be deleted"
+    }
+    return msg
+}
+
+func (e *ChecksumError) Is(target error) bool { return target == ErrChecksumMismatch }
+
+// Checksum is an expected digest. Only SHA-256 is supported; the zero
+// value expects nothing.
+type Checksum struct {
+    Algorithm string // "sha256"
+    Digest    string // lower-case hex
+}
+
+// ParseChecksum reads "sha256:<hex>". A bare 64-digit hex digest is taken
+// as SHA-256 too.
+func ParseChecksum(s string) (Checksum, error) {
+    algo, digest, ok := strings.Cut(strings.TrimSpace(s), ":")
+    if !ok {
+        algo, digest = "sha256", algo
+    }
+    algo = strings.ToLower(algo)
+    if algo != "sha256" {
+        return Checksum{}, fmt.Errorf("unsupported checksum algorithm %q (want sha256)", algo)
+    }
+    digest = strings.ToLower(digest)
+    if !isSHA256Hex(digest) {
+        return Checksum{}, fmt.Errorf("invalid sha256 digest %q", digest)
+    }
+    return Checksum{Algorithm: algo, Digest: digest}, nil
+}
+
+func (c Checksum) String() string {
+    if c.Digest == "" {
+        return ""
+    }
+    return c.Algorithm + ":" + c.Digest
+}
+
+func isSHA256Hex(s string) bool {
+    if len(s) != sha256.Size*2 {
+        return false
+    }
+    _, err := hex.DecodeString(s)
+    return err == nil
+}
+
+// maxSidecarBytes bounds how much of a checksum file is read.
+const maxSidecarBytes = 1 << 20
+
+// findSidecarChecksum looks next to rawURL for <name>.sha256, then for a
+// SHA256SUMS listing one of names. Sidecars that are missing, unreadable
+// or do not mention the file are passed over.
+func findSidecarChecksum(ctx context.Context, client *http.Client, retry RetryPolicy, rawURL string, names ...string) (Checksum, string, bool) {
+    u, err := url.Parse(rawURL)
+    if err != nil || u.Path == "" || strings.HasSuffix(u.Path, "/") {
+        return Checksum{}, "", false
+    }
+    u.RawQuery, u.Fragment = "", ""
+    names = append([]string{path.Base(u.Path)}, names...)
+
+    own := *u
+    own.Path, own.RawPath = u.Path+".sha256", ""
+    sums := *u
+    sums.Path, sums.RawPath = path.Join(path.Dir(u.Path), "SHA256SUMS"), ""
+    for _, side := range []*url.URL{&own, &sums} {
+        body, err := fetchSidecar(ctx, client, retry, side.String())
+        if err != nil {
+            continue
+        }
+        if digest, ok := parseChecksumList(body, side == &own, names); ok {
+            return Checksum{Algorithm: "sha256", Digest: digest}, side.String(), true
+        }
+    }
+    return Checksum{}, "", false
+}
+
+func fetchSidecar(ctx context.Context, client *http.Client, retry RetryPolicy, rawURL string) ([]byte, error) {
+    var body []byte
+    err := retry.Do(ctx, func() error {
+        req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+        if err != nil {
+            return err
+        }
+        resp, err := client.Do(req)
+        if err != nil {
+            return err
+        }
+        defer resp.Body.Close()
+        if err := googleapi.CheckResponse(resp); err != nil {
+            return err
+        }
+        body, err = io.ReadAll(io.LimitReader(resp.Body, maxSidecarBytes))
+        return err
+    })
+    return body, err
+}
+
+// parseChecksumList finds the digest for one of names in sha256sum output
+// ("<hex>  name", "<hex> *name") or BSD-style ("SHA256 (name) = <hex>")
+// lines. With single set, a lone digest with no name also counts, as
+// <file>.sha256 sidecars often hold nothing else.
+func parseChecksumList(data []byte, single bool, names []string) (string, bool) {
+    sc := bufio.NewScanner(strings.NewReader(string(data)))
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        var digest, name string
+        if rest, ok := strings.CutPrefix(line, "SHA256 ("); ok {
+            var found bool
+            if name, digest, found = strings.Cut(rest, ") = "); !found {
+                continue
+            }
+        } else {
+            var found bool
+            digest, name, found = strings.Cut(line, " ")
+            if !found && !single {
+                continue
+            }
+            name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+        }
+        digest = strings.ToLower(strings.TrimSpace(digest))
+        if !isSHA256Hex(digest) {
+            continue
+        }
+        if name == "" && single {
+            return digest, true
+        }
+        name = strings.TrimPrefix(name, "./")
+        for _, n := range names {
+            if n != "" && (name == n || path.Base(name) == n) {
+                return digest, true
+            }
+        }
+    }
+    return "", false
+}
+
+// uploadDigest accumulates the SHA-256 of an upload's content in upload
+// order. Resumable uploads keep its state in the session file next to the
+// offset it covers, so a resumed upload only reads what it sends.
+type uploadDigest struct {
+    h        hash.Hash
+    n        int64 // bytes hashed
+    required bool  // a checksum is expected, so the digest must not be lost
+    lost     bool  // bytes went by unhashed; the digest is unknown
+}
+
+func newUploadDigest(required bool) *uploadDigest {
+    return &uploadDigest{h: sha256.New(), required: required}
+}
+
+func (d *uploadDigest) Write(p []byte) (int, error) {
+    if !d.lost {
+        d.h.Write(p)
+        d.n += int64(len(p))
+    }
+    return len(p), nil
+}
+
+// state returns the hash state to persist, and the bytes it covers.
+func (d *uploadDigest) state() ([]byte, int64) {
+    if d == nil || d.lost {
+        return nil, 0
+    }
+    state, err := d.h.(encoding.BinaryMarshaler).MarshalBinary()
+    if err != nil {
+        return nil, 0
+    }
+    return state, d.n
+}
+
+// restore picks up the state an earlier run persisted. Without one the
+// digest starts over, or is given up when no checksum is expected.
+func (d *uploadDigest) restore(state []byte, n int64) {
+    if d == nil {
+        return
+    }
+    d.h.Reset()
+    d.n, d.lost = 0, false
+    if len(state) > 0 && d.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state) == nil {
+        d.n = n
+        return
+    }
+    d.h.Reset()
+    d.lost = !d.required
+}
+
+// resumeAt returns where to read the source from to resume an upload at
+// committed: before committed when the digest has bytes to catch up on.
+func (d *uploadDigest) resumeAt(committed int64) int64 {
+    if d == nil || d.lost {
+        return committed
+    }
+    if d.n > committed {
+        // The state covers bytes Drive has since dropped.
+        d.restore(nil, 0)
+        if d.lost {
+            return committed
+        }
+    }
+    return d.n
+}
+
+// catchUp hashes the bytes from d.n up to committed out of r, which must
+// start at d.n.
+func (d *uploadDigest) catchUp(r io.Reader, committed int64) error {
+    if d == nil || d.lost || d.n >= committed {
+        return nil
+    }
+    if _, err := io.CopyN(d, r, committed-d.n); err != nil {
+        return fmt.Errorf("hash bytes already uploaded: %w", err)
+    }
+    return nil
+}
+
+// sum returns the digest, or false if it is unknown.
+func (d *uploadDigest) sum() (string, bool) {
+    if d.lost {
+        return "", false
+    }
+    return hex.EncodeToString(d.h.Sum(nil)), true
+}
+
+// checkUpload compares the uploaded content's digest with want, if set. A
+// mismatch deletes the file and returns a *ChecksumError; otherwise the
+// digest, when known, is recorded in the file's appProperties.
+func checkUpload(ctx context.Context, svc *drive.Service, file *drive.File, digest *uploadDigest, want Checksum, sidecar string, retry RetryPolicy) error {
+    sum, ok := digest.sum()
+    if !ok {
+        // Only possible when nothing is expected: an earlier run uploaded
+        // part of the file and left no hash state behind.
+        return nil
+    }
+    if want.Digest != "" && sum != want.Digest {
+        cerr := &ChecksumError{Expected: want, Actual: sum, Sidecar: sidecar}
+        err := retry.Do(ctx, func() error {
+            return svc.Files.Delete(file.Id).Context(ctx).Do()
+        })
+        if err != nil {
+            cerr.FileID = file.Id
+        }
+        return cerr
+    }
+    err := retry.Do(ctx, func() error {
+        _, err := svc.Files.Update(file.Id, &drive.File{
+            AppProperties: map[string]string{ChecksumProperty: sum},
+        }).Context(ctx).Do()
+        return err
+    })
+    if err != nil {
+        return fmt.Errorf("record checksum of %s: %w", file.Id, err)
+    }
+    return nil
+}
@@ -1,12 +1,12 @@
 package downloader
 
 import (
-	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"path/filepath"
 	"sync"
+	"sync/atomic"
 
 	"Streamline/cmd/streamline_core"
 
@@ -15,32 +15,118 @@ import (
 
 const defaultChunkSize = 1 << 20 // 1 MB 
 
+// DefaultCacheBytes bounds the in-memory chunk cache when none is supplied.
+const DefaultCacheBytes = 256 << 20 // 256 MB
+
+// Read-ahead defaults used when DriveReaderOptions leaves them unset.
+const (
+    DefaultReadAhead   = 4
+    DefaultParallelism = 4
+)
+
 // DriveReaderAt provides random-access reads for a Drive file using HTTP Range requests.
 type DriveReaderAt struct {
+    ctx       context.Context
     svc       *drive.Service
     fileID    string
     size      int64
     chunkSize int64
 
-    mu    sync.Mutex
-    cache map[int64][]byte // chunkIndex -> data
+    cache    ChunkCache
+    cacheKey string
+
+    readAhead int
+    sem       chan struct{} // bounds concurrent prefetches
+    retry     RetryPolicy
+
+    mu        sync.Mutex
+    inflight  map[int64]*chunkCall
+    lastChunk int64
+    seqRun    int
+}
+
+// chunkCall is a single in-flight fetch that concurrent readers of the
+// same chunk wait on instead of issuing their own request.
+type chunkCall struct {
+    done     chan struct{}
+    data     []byte
+    err      error
+    prefetch bool
+}
+
+// DriveReaderOptions configures a DriveReaderAt beyond the defaults.
+type DriveReaderOptions struct {
+    // Context bounds every request ReadAt issues, including read-ahead.
+    // Defaults to context.Background(); see also ReadAtContext.
+    Context   context.Context
+    ChunkSize int64
+    // Cache holds fetched chunks. Defaults to a DefaultCacheBytes memory LRU.
+    Cache ChunkCache
+    // CacheKey identifies the file revision in Cache; see ChunkCacheKey.
+    // Defaults to a key derived from the file ID alone.
+    CacheKey string
+    // ReadAhead is how many chunks to fetch ahead once sequential access
+    // is detected. Zero means DefaultReadAhead; negative disables it.
+    ReadAhead int
+    // Parallelism caps concurrent read-ahead requests. Zero means
+    // DefaultParallelism.
+    Parallelism int
+    // Retry governs transient failures of range requests. Zero fields fall
+    // back to DefaultRetryPolicy.
+    Retry RetryPolicy
 }
 
 func NewDriveReaderAt(svc *drive.Service, fileID string, size int64, chunkSize int64) *DriveReaderAt {
-    if chunkSize <= 0 {
-        chunkSize = defaultChunkSize
+    return NewDriveReaderAtWithOptions(svc, fileID, size, DriveReaderOptions{ChunkSize: chunkSize})
+}
+
+func NewDriveReaderAtWithOptions(svc *drive.Service, fileID string, size int64, opts DriveReaderOptions) *DriveReaderAt {
+    if opts.Context == nil {
+        opts.Context = context.Background()
+    }
+    if opts.ChunkSize <= 0 {
+        opts.ChunkSize = defaultChunkSize
+    }
+    if opts.Cache == nil {
+        opts.Cache = NewMemoryCache(DefaultCacheBytes)
+    }
+    if opts.CacheKey == "" {
+        opts.CacheKey = ChunkCacheKey(fileID, "", "")
+    }
+    if opts.ReadAhead == 0 {
+        opts.ReadAhead = DefaultReadAhead
+    }
+    if opts.Parallelism <= 0 {
+        opts.Parallelism = DefaultParallelism
     }
     return &DriveReaderAt{
+        ctx:       opts.Context,
         svc:       svc,
         fileID:    fileID,
         size:      size,
-        chunkSize: chunkSize,
-        cache:     make(map[int64][]byte),
+        chunkSize: opts.ChunkSize,
+        cache:     opts.Cache,
+        // Chunk indexes only line up for the same chunk size.
+        cacheKey:  fmt.Sprintf("%s-%d", opts.CacheKey, opts.ChunkSize),
+        readAhead: opts.ReadAhead,
+        sem:       make(chan struct{}, opts.Parallelism),
+        retry:     opts.Retry,
+        inflight:  make(map[int64]*chunkCall),
+        lastChunk: -1,
     }
 }
 
-// ReadAt implements io.ReaderAt for DriveReaderAt.
+// ReadAt implements io.ReaderAt for DriveReaderAt using the reader's context.
 func (d *DriveReaderAt) ReadAt(p []byte, off int64) (int, error) {
+    return d.ReadAtContext(d.ctx, p, off)
+}
+
+// ReadAtContext is ReadAt bounded by ctx: cancelling it aborts in-flight
+// range requests and retry waits.
+func (d *DriveReaderAt) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
     if off < 0 {
         return 0, fmt.Errorf("negative offset")
     }
@@ -55,32 +141,10 @@ func (d *DriveReaderAt) ReadAt(p []byte, off int64) (int, error) {
     for remaining > 0 && pos < d.size {
         chunkIdx := pos / d.chunkSize
         chunkStart := chunkIdx * d.chunkSize
-        chunkEnd := chunkStart + d.chunkSize - 1
-        if chunkEnd >= d.size {
-            chunkEnd = d.size - 1
-        }
 
-        d.mu.Lock()
-        buf, ok := d.cache[chunkIdx]
-        d.mu.Unlock()
-
-        if !ok {
-            call := d.svc.Files.Get(d.fileID)
-            call.Header().Set("Range", fmt.Sprintf("bytes=%d-%d", chunkStart, chunkEnd))
-            resp, err := call.Download()
-            if err != nil {
-                return n, fmt.Errorf("range download failed: %w", err)
-            }
-            data, err := io.ReadAll(resp.Body)
-            resp.Body.Close()
-            if err != nil {
-                return n, fmt.Errorf("read response failed: %w", err)
-            }
-
-            d.mu.Lock()
-            d.cache[chunkIdx] = data
-            d.mu.Unlock()
-            buf = data
+        buf, err := d.getChunk(ctx, chunkIdx)
+        if err != nil {
+            return n, err
         }
 
         offsetInChunk := pos - chunkStart
@@ -102,6 +166,240 @@ func (d *DriveReaderAt) ReadAt(p []byte, off int64) (int, error) {
     return n, nil
 }
 
+// chunkBounds returns the inclusive byte range covered by chunk idx.
+func (d *DriveReaderAt) chunkBounds(idx int64) (int64, int64) {
+    start := idx * d.chunkSize
+    end := start + d.chunkSize - 1
+    if end >= d.size {
+        end = d.size - 1
+    }
+    return start, end
+}
+
+// cached returns chunk idx from the cache if it is present and complete.
+func (d *DriveReaderAt) cached(idx int64) ([]byte, bool) {
+    buf, ok := d.cache.Get(d.cacheKey, idx)
+    if !ok {
+        return nil, false
+    }
+    start, end := d.chunkBounds(idx)
+    if int64(len(buf)) != end-start+1 {
+        return nil, false
+    }
+    return buf, true
+}
+
+// getChunk returns chunk idx from the cache, joining an in-flight fetch for
+// it if one exists, and otherwise fetching it. Every access feeds the
+// sequential-access detector that drives read-ahead.
+func (d *DriveReaderAt) getChunk(ctx context.Context, idx int64) ([]byte, error) {
+    d.noteAccess(idx)
+
+    if buf, ok := d.cached(idx); ok {
+        return buf, nil
+    }
+
+    for attempt := 0; ; attempt++ {
+        call, leader := d.startFetch(idx, false)
+        if leader {
+            d.runFetch(ctx, idx, call)
+            return call.data, call.err
+        }
+        select {
+        case <-call.done:
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+        // A failed read-ahead, or a fetch abandoned by another reader's
+        // context, shouldn't fail this reader; try once ourselves.
+        if call.err == nil || attempt > 0 || !(call.prefetch || isContextErr(call.err)) {
+            return call.data, call.err
+        }
+    }
+}
+
+func isContextErr(err error) bool {
+    return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// startFetch registers a fetch of chunk idx, or returns the existing one.
+// leader is true when the caller must run the fetch itself.
+func (d *DriveReaderAt) startFetch(idx int64, prefetch bool) (*chunkCall, bool) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if call, ok := d.inflight[idx]; ok {
+        return call, false
+    }
+    call := &chunkCall{done: make(chan struct{}), prefetch: prefetch}
+    d.inflight[idx] = call
+    return call, true
+}
+
+func (d *DriveReaderAt) runFetch(ctx context.Context, idx int64, call *chunkCall) {
+    // A fetch that finished just before we registered has already filled
+    // the cache (runFetch stores before it unregisters).
+    if buf, ok := d.cached(idx); ok {
+        call.data = buf
+    } else {
+        call.data, call.err = d.fetchChunk(ctx, idx)
+        if call.err == nil {
+            d.cache.Put(d.cacheKey, idx, call.data)
+        }
+    }
+    d.mu.Lock()
+    delete(d.inflight, idx)
+    d.mu.Unlock()
+    close(call.done)
+}
+
+func (d *DriveReaderAt) fetchChunk(ctx context.Context, idx int64) ([]byte, error) {
+    start, end := d.chunkBounds(idx)
+    return fetchRange(ctx, d.svc, d.fileID, d.retry, start, end, nil)
+}
+
+// transferStats counts range requests and the response bytes they return.
+type transferStats struct {
+    requests atomic.Int64
+    bytes    atomic.Int64
+}
+
+// fetchRange downloads the inclusive byte range [start, end] of a Drive
+// file. Every attempt, and every body byte it receives, is counted in stats
+// when it is non-nil.
+func fetchRange(ctx context.Context, svc *drive.Service, fileID string, retry RetryPolicy, start, end int64, stats *transferStats) ([]byte, error) {
+    var data []byte
+    err := retry.Do(ctx, func() error {
+        if stats != nil {
+            stats.requests.Add(1)
+        }
+        call := svc.Files.Get(fileID).Context(ctx)
+        call.Header().Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+        resp, err := call.Download()
+        if err != nil {
+            return fmt.Errorf("range download failed: %w", err)
+        }
+        defer resp.Body.Close()
+        data, err = io.ReadAll(resp.Body)
+        if stats != nil {
+            stats.bytes.Add(int64(len(data)))
+        }
+        if err != nil {
+            return fmt.Errorf("read response failed: %w", err)
+        }
+        if int64(len(data)) != end-start+1 {
+            return fmt.Errorf("range download failed: got %d of %d bytes: %w", len(data), end-start+1, io.ErrUnexpectedEOF)
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return data, nil
+}
+
+// RemoteListing is the result of ListDriveZip.
+type RemoteListing struct {
+    Entries []streamline_core.Entry
+    // Requests and BytesTransferred count the range requests issued and the
+    // response bytes received, retries included.
+    Requests         int64
+    BytesTransferred int64
+}
+
+// ListDriveZip lists a ZIP on Drive by fetching only its end records and
+// central directory, each with an exact range request, whatever chunk size
+// extraction would use. Archives that are not ZIPs give
+// streamline_core.ErrUnsupportedFormat; list those with OpenDriveParts.
+func ListDriveZip(ctx context.Context, svc *drive.Service, fileID string, size int64, retry RetryPolicy) (*RemoteListing, error) {
+    r := &rangeReaderAt{ctx: ctx, svc: svc, fileID: fileID, size: size, retry: retry}
+    entries, err := streamline_core.ListZipDirectory(r, size)
+    listing := &RemoteListing{
+        Entries:          entries,
+        Requests:         r.stats.requests.Load(),
+        BytesTransferred: r.stats.bytes.Load(),
+    }
+    return listing, err
+}
+
+// rangeReaderAt turns every ReadAt into one range request for exactly the
+// bytes asked for, with no chunking or caching.
+type rangeReaderAt struct {
+    ctx    context.Context
+    svc    *drive.Service
+    fileID string
+    size   int64
+    retry  RetryPolicy
+    stats  transferStats
+}
+
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (int, error) {
+    if off < 0 {
+        return 0, fmt.Errorf("negative offset")
+    }
+    if off >= r.size {
+        return 0, io.EOF
+    }
+    end := minInt64(off+int64(len(p)), r.size) - 1
+    if end < off {
+        return 0, nil
+    }
+    data, err := fetchRange(r.ctx, r.svc, r.fileID, r.retry, off, end, &r.stats)
+    n := copy(p, data)
+    if err == nil && n < len(p) {
+        err = io.EOF
+    }
+    return n, err
+}
+
+// noteAccess tracks runs of consecutive chunk reads and, once a run is
+// seen, schedules the next readAhead chunks in the background.
+func (d *DriveReaderAt) noteAccess(idx int64) {
+    if d.readAhead <= 0 {
+        return
+    }
+    d.mu.Lock()
+    switch idx {
+    case d.lastChunk:
+    case d.lastChunk + 1:
+        d.seqRun++
+    default:
+        d.seqRun = 0
+    }
+    d.lastChunk = idx
+    sequential := d.seqRun > 0
+    d.mu.Unlock()
+
+    if !sequential {
+        return
+    }
+    last := (d.size - 1) / d.chunkSize
+    for next := idx + 1; next <= idx+int64(d.readAhead) && next <= last; next++ {
+        d.prefetch(next)
+    }
+}
+
+// prefetch fetches chunk idx in the background unless it is cached, already
+// in flight, or the parallelism budget is spent.
+func (d *DriveReaderAt) prefetch(idx int64) {
+    if _, ok := d.cached(idx); ok {
+        return
+    }
+    select {
+    case d.sem <- struct{}{}:
+    default:
+        return
+    }
+    call, leader := d.startFetch(idx, true)
+    if !leader {
+        <-d.sem
+        return
+    }
+    go func() {
+        defer func() { <-d.sem }()
+        d.runFetch(d.ctx, idx, call)
+    }()
+}
+
 func minInt64(a, b int64) int64 {
     if a < b {
         return a
@@ -109,39 +407,80 @@ func minInt64(a, b int64) int64 {
     return b
 }
 
-// DriveExtractor implements Downloader for extracting a ZIP from Drive.
+// OpenDriveParts opens Drive files as one io.ReaderAt. A single ID is read
+// as-is; several IDs are the disks of a split ZIP in order (.z01, .z02, ...,
+// .zip) and are joined with streamline_core.JoinSplitZip. opts.CacheKey is
+// ignored: each part is cached under its own revision. The parts' metadata
+// is returned alongside.
+func OpenDriveParts(ctx context.Context, svc *drive.Service, fileIDs []string, opts DriveReaderOptions) (*io.SectionReader, []*drive.File, error) {
+    if len(fileIDs) == 0 {
+        return nil, nil, fmt.Errorf("no Drive file IDs given")
+    }
+    parts := make([]*io.SectionReader, 0, len(fileIDs))
+    metas := make([]*drive.File, 0, len(fileIDs))
+    for _, id := range fileIDs {
+        meta, err := svc.Files.Get(id).Fields("name,size,md5Checksum,modifiedTime").Context(ctx).Do()
+        if err != nil {
+            return nil, nil, fmt.Errorf("get file metadata for %s: %w", id, err)
+        }
+        if meta.Size == 0 {
+            return nil, nil, fmt.Errorf("file %s size is 0 or unknown; ensure it's an archive and accessible", id)
+        }
+        partOpts := opts
+        partOpts.CacheKey = ChunkCacheKey(id, meta.Md5Checksum, meta.ModifiedTime)
+        parts = append(parts, io.NewSectionReader(NewDriveReaderAtWithOptions(svc, id, meta.Size, partOpts), 0, meta.Size))
+        metas = append(metas, meta)
+    }
+    if len(parts) == 1 {
+        return parts[0], metas, nil
+    }
+    joined, err := streamline_core.JoinSplitZip(parts...)
+    if err != nil {
+        return nil, nil, err
+    }
+    return joined, metas, nil
+}
+
+// DriveExtractor implements Downloader for extracting an archive from Drive.
 type DriveExtractor struct {
-    FileID string
-    OutDir string
+    FileID   string
+    OutDir   string
+    Cache    ChunkCache                  // optional; defaults to an in-memory LRU
+    Password string                      // optional; for encrypted archives
+    Paths    streamline_core.PathMapping // optional; remaps entry paths under OutDir
+    ToDrive  bool                        // extract into the target Drive folder instead of OutDir
+    Limits   streamline_core.Limits      // zero means streamline_core.DefaultLimits
+    Retry    RetryPolicy                 // for range requests and Drive folder creation
 }
 
 func (d *DriveExtractor) DownloadAndUpload(ctx context.Context, svc *drive.Service, targetFolderID string) (string, error) {
-    // Reuse your existing extraction logic from main()
-    meta, err := svc.Files.Get(d.FileID).Fields("name,size").Do()
+    r, _, err := OpenDriveParts(ctx, svc, []string{d.FileID}, DriveReaderOptions{
+        Context: ctx,
+        Cache:   d.Cache,
+        Retry:   d.Retry,
+    })
     if err != nil {
-        return "", fmt.Errorf("get file metadata: %w", err)
-    }
-    if meta.Size == 0 {
-        return "", fmt.Errorf("file size is 0 or unknown; ensure it's a ZIP and accessible")
+        return "", err
     }
-
-    readerAt := NewDriveReaderAt(svc, d.FileID, meta.Size, defaultChunkSize)
-    zr, err := zip.NewReader(readerAt, meta.Size)
+    arc, err := streamline_core.NewArchiveWithOptions(r, r.Size(), streamline_core.ArchiveOptions{Password: d.Password})
     if err != nil {
-        return "", fmt.Errorf("zip reader: %w", err)
+        return "", fmt.Errorf("open archive: %w", err)
     }
+    defer arc.Close()
 
-    // Extract files into d.OutDir (reuse your existing loop logic)
-    for _, f := range zr.File {
-        targetPath := filepath.Join(d.OutDir, f.Name)
-        if !streamline_core.IsPathWithinBase(d.OutDir, targetPath) {
-            return "", fmt.Errorf("illegal file path: %s", targetPath)
-        }
-        if err := streamline_core.ExtractFile(f, targetPath); err != nil {
-            return "", fmt.Errorf("extract %s: %w", f.Name, err)
-        }
+    opts := streamline_core.ExtractOptions{Paths: d.Paths, Limits: d.Limits}
+    if opts.Limits == (streamline_core.Limits{}) {
+        opts.Limits = streamline_core.DefaultLimits
+    }
+    if d.ToDrive {
+        _, err = ExtractToDrive(ctx, svc, arc, targetFolderID, opts, d.Retry)
+    } else {
+        _, err = arc.Extract(ctx, d.OutDir, opts)
+    }
+    if err != nil {
+        return "", err
     }
 
     // Return the Drive file ID we just extracted from
     return d.FileID, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"Streamline/cmd/streamline_core"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// DriveWriter stores archive entries as files in a Drive folder, recreating
+// the archive's directories as Drive folders beneath it. Each entry streams
+// straight from its reader into an upload, so nothing touches local disk.
+// It implements streamline_core.EntryWriter; see ExtractToDrive.
+//
+// Folders are created fresh on every run, never matched against what is
+// already in the target. A DriveWriter is not safe for concurrent use.
+type DriveWriter struct {
+    Context  context.Context // bounds every request; defaults to Background
+    Svc      *drive.Service
+    FolderID string      // the folder entries are placed under
+    Retry    RetryPolicy // for folder creation; uploads stream once and are not retried
+
+    // Files and Folders count what has been created so far.
+    Files, Folders int
+
+    folders map[string]string // archive dir path -> Drive folder ID
+}
+
+func (w *DriveWriter) ctx() context.Context {
+    if w.Context != nil {
+        return w.Context
+    }
+    return context.Background()
+}
+
+// Add creates the folder for a directory entry, or uploads r as a file
+// named after the last element of name inside the folders for the rest.
+// Symlinks have no Drive equivalent and are refused; ExtractToDrive skips
+// them before they get here.
+func (w *DriveWriter) Add(name string, e streamline_core.Entry, r io.Reader) error {
+    name = strings.TrimSuffix(name, "/")
+    if !fs.ValidPath(name) || name == "." {
+        return fmt.Errorf("illegal path: %s", name)
+    }
+    if e.IsSymlink() {
+        return fmt.Errorf("%s: symlinks cannot be stored in Drive", name)
+    }
+    if e.IsDir {
+        _, err := w.folder(name)
+        return err
+    }
+
+    parent, err := w.folder(path.Dir(name))
+    if err != nil {
+        return err
+    }
+    f := &drive.File{Name: path.Base(name), Parents: []string{parent}}
+    if !e.Modified.IsZero() {
+        f.ModifiedTime = e.Modified.UTC().Format(time.RFC3339)
+    }
+    call := w.Svc.Files.Create(f).Fields("id").Context(w.ctx())
+    if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+        call.Media(r, googleapi.ContentType(ct))
+    } else {
+        call.Media(r)
+    }
+    if _, err := call.Do(); err != nil {
+        return fmt.Errorf("upload %s: %w", name, err)
+    }
+    w.Files++
+    return nil
+}
+
+// folder returns the Drive folder for the archive directory dir ("." for
+// the top), creating it and any missing parents.
+func (w *DriveWriter) folder(dir string) (string, error) {
+    if dir == "." {
+        return w.FolderID, nil
+    }
+    if w.folders == nil {
+        w.folders = make(map[string]string)
+    }
+    if id, ok := w.folders[dir]; ok {
+        return id, nil
+    }
+    parent, err := w.folder(path.Dir(dir))
+    if err != nil {
+        return "", err
+    }
+    var created *drive.File
+    err = w.Retry.Do(w.ctx(), func() error {
+        var err error
+        created, err = w.Svc.Files.Create(&drive.File{
+            Name:     path.Base(dir),
+            MimeType: DriveFolderMimeType,
+            Parents:  []string{parent},
+        }).Fields("id").Context(w.ctx()).Do()
+        return err
+    })
+    if err != nil {
+        return "", fmt.Errorf("create folder %s: %w", dir, err)
+    }
+    w.folders[dir] = created.Id
+    w.Folders++
+    return created.Id, nil
+}
+
+// ExtractToDrive copies the entries of a that opts.Select picks into the
+// Drive folder folderID, named as opts.Paths maps them, without writing
+// anything locally. Symlinks are skipped. Limits, OnEntry and
+// ContinueOnError apply as for streamline_core.Pack; options that concern
+// files on disk do not. retry governs folder creation.
+func ExtractToDrive(ctx context.Context, svc *drive.Service, a streamline_core.Archive, folderID string, opts streamline_core.ExtractOptions, retry RetryPolicy) (*streamline_core.ExtractSummary, error) {
+    sel := opts.Select
+    opts.Select = func(e streamline_core.Entry) bool {
+        return !e.IsSymlink() && (sel == nil || sel(e))
+    }
+    w := &DriveWriter{Context: ctx, Svc: svc, FolderID: folderID, Retry: retry}
+    return streamline_core.Repack(ctx, a, w, opts)
+}
@@ -0,0 +1,292 @@
+package downloader
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// DefaultURLSegments and DefaultSegmentSize shape segmented URL downloads
+// when URLDownloader leaves them zero. At most Segments*SegmentSize bytes
+// are held in memory ahead of the upload.
+const (
+    DefaultURLSegments = 4
+    DefaultSegmentSize = 8 << 20
+)
+
+// ErrSourceChanged means a URL's content changed while it was being read:
+// its ETag or Last-Modified no longer matches, or the server stopped
+// answering range requests. Stitching the bytes together would corrupt the
+// file, so the download stops.
+var ErrSourceChanged = errors.New("source changed during download")
+
+// defaultHTTPClient bounds connecting and waiting for headers, never the
+// body: a large download may legitimately take hours.
+var defaultHTTPClient = &http.Client{
+    Transport: &http.Transport{
+        Proxy:                 http.ProxyFromEnvironment,
+        DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+        TLSHandshakeTimeout:   10 * time.Second,
+        ResponseHeaderTimeout: 60 * time.Second,
+        IdleConnTimeout:       90 * time.Second,
+        MaxIdleConnsPerHost:   DefaultURLSegments * 2,
+    },
+}
+
+// httpSource is a URL as probed: whether it serves byte ranges, its size
+// and the validator that pins the version being read.
+type httpSource struct {
+    client   *http.Client
+    url      string
+    retry    RetryPolicy
+    segments int
+    segSize  int64
+
+    size      int64  // -1 when unknown
+    ranges    bool   // ranged reads work, so reads can resume and run in parallel
+    etag      string // strong ETag, if any
+    validator string // If-Range value: the ETag, else Last-Modified
+    header    http.Header
+    finalURL  string // after redirects
+    head      []byte // up to sniffLen bytes from the start, for content sniffing
+
+    first io.ReadCloser // the probe's body, when it is the whole content
+}
+
+// sniffLen is how much content http.DetectContentType looks at.
+const sniffLen = 512
+
+// probeURL asks for the first sniffLen bytes. A 206 with a known total
+// means ranges work; a 200 means they do not, and its body is kept as the
+// one stream the content can be read from.
+func probeURL(ctx context.Context, client *http.Client, url string, retry RetryPolicy) (*httpSource, error) {
+    src := &httpSource{client: client, url: url, retry: retry, size: -1}
+    err := retry.Do(ctx, func() error {
+        resp, err := src.get(ctx, fmt.Sprintf("bytes=0-%d", sniffLen-1), "")
+        if err != nil {
+            return err
+        }
+        if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+            // Nothing to range over: the content is empty or the server
+            // refuses ranges outright. Fetch it whole.
+            resp.Body.Close()
+            if resp, err = src.get(ctx, "", ""); err != nil {
+                return err
+            }
+        }
+        if err := googleapi.CheckResponse(resp); err != nil {
+            resp.Body.Close()
+            return err
+        }
+        src.header, src.finalURL = resp.Header, resp.Request.URL.String()
+        if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+            src.etag, src.validator = etag, etag
+        } else if lm := resp.Header.Get("Last-Modified"); lm != "" {
+            src.validator = lm
+        }
+        if resp.StatusCode == http.StatusPartialContent {
+            head, err := io.ReadAll(io.LimitReader(resp.Body, sniffLen))
+            resp.Body.Close()
+            if err != nil {
+                return err
+            }
+            if total, ok := contentRangeTotal(resp.Header.Get("Content-Range")); ok {
+                src.size, src.ranges, src.head = total, true, head
+                return nil
+            }
+            // A range whose total is unknown cannot be split up; start over
+            // with the whole thing.
+            if resp, err = src.get(ctx, "", ""); err != nil {
+                return err
+            }
+            if err := googleapi.CheckResponse(resp); err != nil {
+                resp.Body.Close()
+                return err
+            }
+        }
+        br := bufio.NewReaderSize(resp.Body, sniffLen)
+        head, err := br.Peek(sniffLen)
+        if err != nil && err != io.EOF {
+            resp.Body.Close()
+            return err
+        }
+        src.head = append([]byte(nil), head...)
+        src.size = resp.ContentLength
+        src.first = struct {
+            io.Reader
+            io.Closer
+        }{br, resp.Body}
+        return nil
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to fetch URL: %w", err)
+    }
+    return src, nil
+}
+
+// contentRangeTotal reads the complete length from "bytes a-b/total".
+func contentRangeTotal(cr string) (int64, bool) {
+    i := strings.LastIndexByte(cr, '/')
+    if !strings.HasPrefix(cr, "bytes ") || i < 0 {
+        return 0, false
+    }
+    total, err := strconv.ParseInt(cr[i+1:], 10, 64)
+    return total, err == nil && total >= 0
+}
+
+func (s *httpSource) get(ctx context.Context, rng, ifRange string) (*http.Response, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+    if err != nil {
+        return nil, err
+    }
+    if rng != "" {
+        req.Header.Set("Range", rng)
+    }
+    if ifRange != "" {
+        req.Header.Set("If-Range", ifRange)
+    }
+    return s.client.Do(req)
+}
+
+// open returns the content from offset on: in parallel segments when the
+// server allows ranges, else from the single stream, dropping the bytes
+// before offset.
+func (s *httpSource) open(ctx context.Context, offset int64) (io.ReadCloser, error) {
+    if s.ranges {
+        return newSegmentReader(ctx, s, offset), nil
+    }
+    body := s.first
+    s.first = nil
+    if body == nil {
+        resp, err := s.get(ctx, "", "")
+        if err != nil {
+            return nil, fmt.Errorf("failed to fetch URL: %w", err)
+        }
+        if err := googleapi.CheckResponse(resp); err != nil {
+            resp.Body.Close()
+            return nil, fmt.Errorf("failed to fetch URL: %w", err)
+        }
+        body = resp.Body
+    }
+    if offset > 0 {
+        if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+            body.Close()
+            return nil, fmt.Errorf("skip to byte %d: %w", offset, err)
+        }
+    }
+    return body, nil
+}
+
+// close releases the probe's stream if it was never used.
+func (s *httpSource) close() {
+    if s.first != nil {
+        s.first.Close()
+        s.first = nil
+    }
+}
+
+// fetchSegment reads [start, end] into memory. A failed attempt keeps what
+// arrived and the retry asks only for the rest.
+func (s *httpSource) fetchSegment(ctx context.Context, start, end int64) ([]byte, error) {
+    buf := make([]byte, 0, end-start+1)
+    err := s.retry.Do(ctx, func() error {
+        from := start + int64(len(buf))
+        resp, err := s.get(ctx, fmt.Sprintf("bytes=%d-%d", from, end), s.validator)
+        if err != nil {
+            return err
+        }
+        defer resp.Body.Close()
+        switch {
+        case resp.StatusCode == http.StatusOK:
+            // If-Range answers with the whole, newer content.
+            return ErrSourceChanged
+        case resp.StatusCode != http.StatusPartialContent:
+            return googleapi.CheckResponse(resp)
+        case !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", from)):
+            return fmt.Errorf("asked for byte %d, got Content-Range %q", from, resp.Header.Get("Content-Range"))
+        case s.etag != "" && resp.Header.Get("ETag") != "" && resp.Header.Get("ETag") != s.etag:
+            // Some servers ignore If-Range; the ETag still gives them away.
+            return ErrSourceChanged
+        }
+        n, err := io.ReadFull(resp.Body, buf[len(buf):cap(buf)])
+        buf = buf[:len(buf)+n]
+        return err
+    })
+    if err != nil {
+        return nil, fmt.Errorf("bytes %d-%d: %w", start, end, err)
+    }
+    return buf, nil
+}
+
+// segmentReader reads a ranged source in order while keeping up to
+// segments requests for the following ranges in flight.
+type segmentReader struct {
+    src    *httpSource
+    ctx    context.Context
+    cancel context.CancelFunc
+
+    next    int64 // start of the next segment to request
+    pending []chan segmentResult
+    cur     []byte
+    err     error
+}
+
+type segmentResult struct {
+    data []byte
+    err  error
+}
+
+func newSegmentReader(ctx context.Context, src *httpSource, offset int64) *segmentReader {
+    ctx, cancel := context.WithCancel(ctx)
+    return &segmentReader{src: src, ctx: ctx, cancel: cancel, next: offset}
+}
+
+func (r *segmentReader) Read(p []byte) (int, error) {
+    for len(r.cur) == 0 {
+        if r.err != nil {
+            return 0, r.err
+        }
+        segments, size := r.src.segments, r.src.segSize
+        if segments <= 0 {
+            segments = DefaultURLSegments
+        }
+        if size <= 0 {
+            size = DefaultSegmentSize
+        }
+        for len(r.pending) < segments && r.next < r.src.size {
+            start, end := r.next, min(r.next+size, r.src.size)-1
+            ch := make(chan segmentResult, 1)
+            go func() {
+                data, err := r.src.fetchSegment(r.ctx, start, end)
+                ch <- segmentResult{data, err}
+            }()
+            r.pending = append(r.pending, ch)
+            r.next = end + 1
+        }
+        if len(r.pending) == 0 {
+            r.err = io.EOF
+            continue
+        }
+        res := <-r.pending[0]
+        r.pending = r.pending[1:]
+        r.cur, r.err = res.data, res.err
+    }
+    n := copy(p, r.cur)
+    r.cur = r.cur[n:]
+    return n, nil
+}
+
+// Close abandons the segments still in flight.
+func (r *segmentReader) Close() error {
+    r.cancel()
+    return nil
+}
@@ -0,0 +1,263 @@
+package downloader
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrAddressNotAllowed reports a connection HTTPOptions.PublicOnly refused.
+var ErrAddressNotAllowed = errors.New("address not allowed")
+
+// HTTPOptions customizes the requests URLDownloader makes. Credentials and
+// headers are sent only to the download's own host, never to hosts it
+// redirects to; those get credentials from NetrcFile, if it has any.
+type HTTPOptions struct {
+    Header      http.Header    // extra request headers
+    Cookies     []*http.Cookie // sent as a Cookie header
+    Username    string         // basic auth, when set
+    Password    string
+    BearerToken string // sent as "Authorization: Bearer ..."; wins over basic auth
+    UserAgent   string // replaces Go's default, on every request
+    // Proxy is an http, https or socks5 URL; empty uses HTTP_PROXY and
+    // friends from the environment.
+    Proxy string
+    // CABundle is a PEM file of certificates trusted in addition to the
+    // system roots.
+    CABundle string
+    // NetrcFile holds per-host credentials in netrc format. They apply to
+    // hosts without explicit credentials above.
+    NetrcFile string
+    // PublicOnly refuses connections to loopback, link-local, private and
+    // other non-public addresses, redirects included, unless AllowNets
+    // holds them. Servers fetching URLs for others should set it. It
+    // connects directly, so it cannot be combined with a proxy.
+    PublicOnly bool
+    AllowNets  []netip.Prefix
+}
+
+// client builds an HTTP client for a download from rawURL.
+func (o HTTPOptions) client(rawURL string) (*http.Client, error) {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return nil, fmt.Errorf("parse URL: %w", err)
+    }
+    base := defaultHTTPClient.Transport.(*http.Transport).Clone()
+    if o.PublicOnly {
+        if o.Proxy != "" {
+            return nil, fmt.Errorf("a proxy cannot be used with PublicOnly")
+        }
+        // The address checked must be the server's, not a proxy's.
+        base.Proxy = nil
+        dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: o.checkAddress}
+        base.DialContext = dialer.DialContext
+    }
+    if o.Proxy != "" {
+        proxy, err := url.Parse(o.Proxy)
+        if err != nil || proxy.Host == "" {
+            return nil, fmt.Errorf("invalid proxy %q", o.Proxy)
+        }
+        base.Proxy = http.ProxyURL(proxy)
+    }
+    if o.CABundle != "" {
+        pem, err := os.ReadFile(o.CABundle)
+        if err != nil {
+            return nil, fmt.Errorf("read CA bundle: %w", err)
+        }
+        pool, err := x509.SystemCertPool()
+        if err != nil {
+            pool = x509.NewCertPool()
+        }
+        if !pool.AppendCertsFromPEM(pem) {
+            return nil, fmt.Errorf("CA bundle %s holds no PEM certificates", o.CABundle)
+        }
+        base.TLSClientConfig = &tls.Config{RootCAs: pool}
+    }
+    t := &authTransport{base: base, host: u.Hostname(), opts: o}
+    if o.NetrcFile != "" {
+        f, err := os.Open(o.NetrcFile)
+        if err != nil {
+            return nil, fmt.Errorf("read netrc: %w", err)
+        }
+        t.netrc, err = parseNetrc(f)
+        f.Close()
+        if err != nil {
+            return nil, fmt.Errorf("read netrc %s: %w", o.NetrcFile, err)
+        }
+    }
+    return &http.Client{Transport: t}, nil
+}
+
+// checkAddress is a net.Dialer Control function that enforces PublicOnly
+// on the address actually being dialed, after DNS resolution.
+func (o HTTPOptions) checkAddress(network, address string, _ syscall.RawConn) error {
+    ap, err := netip.ParseAddrPort(address)
+    if err != nil {
+        return err
+    }
+    addr := ap.Addr().Unmap()
+    if isPublicAddr(addr) || slices.ContainsFunc(o.AllowNets, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+        return nil
+    }
+    return fmt.Errorf("%w: %s is not a public address", ErrAddressNotAllowed, addr)
+}
+
+// isPublicAddr reports whether addr is a global unicast address outside
+// the private, shared (carrier-grade NAT) and documentation ranges.
+func isPublicAddr(addr netip.Addr) bool {
+    if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+        return false
+    }
+    for _, p := range nonPublicNets {
+        if p.Contains(addr) {
+            return false
+        }
+    }
+    return true
+}
+
+var nonPublicNets = []netip.Prefix{
+    netip.MustParsePrefix("100.64.0.0/10"),
+    netip.MustParsePrefix("192.0.0.0/24"),
+    netip.MustParsePrefix("192.0.2.0/24"),
+    netip.MustParsePrefix("198.18.0.0/15"),
+    netip.MustParsePrefix("198.51.100.0/24"),
+    netip.MustParsePrefix("203.0.113.0/24"),
+    netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// authTransport adds HTTPOptions' headers and credentials to each request,
+// redirects included, according to the host it is going to.
+type authTransport struct {
+    base  http.RoundTripper
+    host  string // the download's host, which explicit credentials belong to
+    opts  HTTPOptions
+    netrc []netrcEntry
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    req = req.Clone(req.Context())
+    if t.opts.UserAgent != "" {
+        req.Header.Set("User-Agent", t.opts.UserAgent)
+    }
+    if req.URL.Hostname() == t.host {
+        for k, vs := range t.opts.Header {
+            req.Header[http.CanonicalHeaderKey(k)] = vs
+        }
+        for _, c := range t.opts.Cookies {
+            req.AddCookie(c)
+        }
+        switch {
+        case t.opts.BearerToken != "":
+            req.Header.Set("Authorization", "Bearer "+t.opts.BearerToken)
+        case t.opts.Username != "":
+            req.SetBasicAuth(t.opts.Username, t.opts.Password)
+        }
+    }
+    if req.Header.Get("Authorization") == "" {
+        if e, ok := lookupNetrc(t.netrc, req.URL.Hostname()); ok {
+            req.SetBasicAuth(e.login, e.password)
+        }
+    }
+    return t.base.RoundTrip(req)
+}
+
+// netrcEntry is one machine (or the default, with an empty machine).
+type netrcEntry struct {
+    machine, login, password string
+}
+
+// parseNetrc reads the machine, default, login and password tokens of a
+// netrc file; account and macdef definitions are skipped.
+func parseNetrc(r io.Reader) ([]netrcEntry, error) {
+    var entries []netrcEntry
+    var cur *netrcEntry
+    sc := bufio.NewScanner(r)
+    inMacro := false
+    var tokens []string
+    for sc.Scan() {
+        line := sc.Text()
+        if inMacro {
+            // A macro runs to the next blank line.
+            inMacro = strings.TrimSpace(line) != ""
+            continue
+        }
+        if strings.HasPrefix(strings.TrimSpace(line), "#") {
+            continue
+        }
+        fields := strings.Fields(line)
+        for i := 0; i < len(fields); i++ {
+            if fields[i] == "macdef" {
+                inMacro = true
+                break
+            }
+            tokens = append(tokens, fields[i])
+        }
+    }
+    if err := sc.Err(); err != nil {
+        return nil, err
+    }
+    for i := 0; i < len(tokens); i++ {
+        switch tokens[i] {
+        case "default":
+            entries = append(entries, netrcEntry{})
+            cur = &entries[len(entries)-1]
+            continue
+        case "machine", "login", "password", "account":
+        default:
+            return nil, fmt.Errorf("unexpected token %q", tokens[i])
+        }
+        if i+1 >= len(tokens) {
+            return nil, fmt.Errorf("%s needs a value", tokens[i])
+        }
+        key, value := tokens[i], tokens[i+1]
+        i++
+        if key == "machine" {
+            entries = append(entries, netrcEntry{machine: value})
+            cur = &entries[len(entries)-1]
+            continue
+        }
+        if cur == nil {
+            return nil, fmt.Errorf("%s before any machine", key)
+        }
+        switch key {
+        case "login":
+            cur.login = value
+        case "password":
+            cur.password = value
+        }
+    }
+    return entries, nil
+}
+
+// lookupNetrc finds the entry for host, else the default entry.
+func lookupNetrc(entries []netrcEntry, host string) (netrcEntry, bool) {
+    var def *netrcEntry
+    for i, e := range entries {
+        if e.machine == "" {
+            if def == nil {
+                def = &entries[i]
+            }
+            continue
+        }
+        if strings.EqualFold(e.machine, host) {
+            return e, e.login != ""
+        }
+    }
+    if def != nil {
+        return *def, def.login != ""
+    }
+    return netrcEntry{}, false
+}
@@ -0,0 +1,321 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"Streamline/cmd/streamline_core"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// DriveFolderMimeType is the MIME type Drive gives folders.
+const DriveFolderMimeType = "application/vnd.google-apps.folder"
+
+// DriveFolderSource walks a Drive folder tree for streamline_core.Pack.
+// Google Docs and other native files have no bytes to download and are
+// left out.
+type DriveFolderSource struct {
+    Svc      *drive.Service
+    FolderID string
+    Retry    RetryPolicy
+}
+
+func (s *DriveFolderSource) Walk(ctx context.Context, fn func(e streamline_core.Entry, open func() (io.ReadCloser, error)) error) error {
+    return s.walk(ctx, s.FolderID, "", fn)
+}
+
+func (s *DriveFolderSource) walk(ctx context.Context, folderID, prefix string, fn func(e streamline_core.Entry, open func() (io.ReadCloser, error)) error) error {
+    children, err := listFolder(ctx, s.Svc, folderID, s.Retry)
+    if err != nil {
+        return err
+    }
+    for _, f := range children {
+        // Drive allows "/" in names; keep it from inventing directories.
+        name := prefix + strings.ReplaceAll(f.Name, "/", "_")
+        e := streamline_core.Entry{Name: name, Size: f.Size, Mode: 0o644}
+        if t, err := time.Parse(time.RFC3339, f.ModifiedTime); err == nil {
+            e.Modified = t
+        }
+        switch {
+        case f.MimeType == DriveFolderMimeType:
+            e.Name, e.IsDir, e.Mode = name+"/", true, os.ModeDir|0o755
+            if err := fn(e, nil); err != nil {
+                return err
+            }
+            if err := s.walk(ctx, f.Id, e.Name, fn); err != nil {
+                return err
+            }
+        case strings.HasPrefix(f.MimeType, "application/vnd.google-apps."):
+            continue
+        default:
+            id := f.Id
+            open := func() (io.ReadCloser, error) {
+                var body io.ReadCloser
+                err := s.Retry.Do(ctx, func() error {
+                    resp, err := s.Svc.Files.Get(id).Context(ctx).Download()
+                    if err != nil {
+                        return fmt.Errorf("download %s: %w", name, err)
+                    }
+                    body = resp.Body
+                    return nil
+                })
+                return body, err
+            }
+            if err := fn(e, open); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// listFolder returns the untrashed children of a Drive folder, folders
+// first, each group by name.
+func listFolder(ctx context.Context, svc *drive.Service, folderID string, retry RetryPolicy) ([]*drive.File, error) {
+    var files []*drive.File
+    pageToken := ""
+    for {
+        call := svc.Files.List().
+            Q(fmt.Sprintf("'%s' in parents and trashed = false", strings.ReplaceAll(folderID, "'", `\'`))).
+            Fields("nextPageToken, files(id, name, mimeType, size, modifiedTime)").
+            OrderBy("folder,name").
+            PageSize(1000).
+            Context(ctx)
+        if pageToken != "" {
+            call.PageToken(pageToken)
+        }
+        var list *drive.FileList
+        err := retry.Do(ctx, func() error {
+            var err error
+            list, err = call.Do()
+            return err
+        })
+        if err != nil {
+            return nil, fmt.Errorf("list folder %s: %w", folderID, err)
+        }
+        files = append(files, list.Files...)
+        if pageToken = list.NextPageToken; pageToken == "" {
+            return files, nil
+        }
+    }
+}
+
+// Packer builds a ZIP or tar.gz from a local directory or a Drive folder
+// and writes it to disk or streams it into Drive, without staging the
+// archive anywhere.
+type Packer struct {
+    Dir      string // local directory to pack, or
+    FolderID string // Drive folder to pack
+
+    // Format is FormatZip or FormatTarGz; empty picks one from the output
+    // name's extension.
+    Format streamline_core.Format
+    // Level is a compression level as for streamline_core.NewArchiveWriter.
+    Level int
+    // Options filter, rename and meter entries as for streamline_core.Pack.
+    Options streamline_core.ExtractOptions
+
+    OutPath string      // write the archive here instead of uploading it
+    Name    string      // uploaded file name; defaults to the source's name
+    Retry   RetryPolicy // for reading a Drive folder
+}
+
+// PackResult reports what Packer.Pack produced.
+type PackResult struct {
+    FileID  string // the uploaded Drive file, or
+    Path    string // the file written to disk
+    Bytes   int64  // archive size
+    Summary *streamline_core.ExtractSummary
+}
+
+// DownloadAndUpload implements Downloader: the archive goes into
+// targetFolderID, or to OutPath when set, whose path is then returned.
+func (p *Packer) DownloadAndUpload(ctx context.Context, svc *drive.Service, targetFolderID string) (string, error) {
+    res, err := p.Pack(ctx, svc, targetFolderID)
+    if err != nil {
+        return "", err
+    }
+    if res.FileID != "" {
+        return res.FileID, nil
+    }
+    return res.Path, nil
+}
+
+// Pack builds the archive. svc may be nil when packing a local directory
+// to disk.
+func (p *Packer) Pack(ctx context.Context, svc *drive.Service, targetFolderID string) (*PackResult, error) {
+    if (p.Dir == "") == (p.FolderID == "") {
+        return nil, fmt.Errorf("pack: give exactly one of a directory or a Drive folder")
+    }
+    var src streamline_core.PackSource = streamline_core.DirSource{Dir: p.Dir}
+    if p.FolderID != "" {
+        if svc == nil {
+            return nil, fmt.Errorf("pack: a Drive service is needed to read a Drive folder")
+        }
+        src = &DriveFolderSource{Svc: svc, FolderID: p.FolderID, Retry: p.Retry}
+    } else if st, err := os.Stat(p.Dir); err != nil {
+        return nil, fmt.Errorf("pack: %w", err)
+    } else if !st.IsDir() {
+        return nil, fmt.Errorf("pack: %s is not a directory", p.Dir)
+    }
+
+    if p.OutPath != "" {
+        return p.packToDisk(ctx, src)
+    }
+    if svc == nil {
+        return nil, fmt.Errorf("pack: a Drive service is needed to upload")
+    }
+    name, err := p.uploadName(ctx, svc)
+    if err != nil {
+        return nil, err
+    }
+    format := p.format(name)
+
+    pr, pw := io.Pipe()
+    counter := &countingWriter{w: pw}
+    type packed struct {
+        summary *streamline_core.ExtractSummary
+        err     error
+    }
+    done := make(chan packed, 1)
+    go func() {
+        summary, err := p.write(ctx, src, counter, format)
+        pw.CloseWithError(err)
+        done <- packed{summary, err}
+    }()
+
+    created, err := svc.Files.Create(&drive.File{
+        Name:     name,
+        Parents:  []string{targetFolderID},
+        MimeType: packMimeType(format),
+    }).Media(pr, googleapi.ContentType(packMimeType(format))).Context(ctx).Do()
+    // Unblock the packer if the upload gave up first.
+    pr.CloseWithError(err)
+    result := <-done
+    if result.err != nil {
+        return &PackResult{Summary: result.summary}, result.err
+    }
+    if err != nil {
+        return &PackResult{Summary: result.summary}, fmt.Errorf("upload to Drive failed: %w", err)
+    }
+    return &PackResult{FileID: created.Id, Bytes: counter.n, Summary: result.summary}, nil
+}
+
+// packToDisk writes beside OutPath and renames into place once complete,
+// so a failed run never leaves a truncated archive behind.
+func (p *Packer) packToDisk(ctx context.Context, src streamline_core.PackSource) (*PackResult, error) {
+    opts := p.Options
+    if p.Dir != "" {
+        // Never pack the archive being written, or its temp file, into itself.
+        absOut, _ := filepath.Abs(p.OutPath)
+        absDir, _ := filepath.Abs(p.Dir)
+        if rel, err := filepath.Rel(absDir, filepath.Dir(absOut)); err == nil && !strings.HasPrefix(rel, "..") {
+            prefix := filepath.ToSlash(filepath.Join(rel, "."+filepath.Base(absOut)))
+            self := filepath.ToSlash(filepath.Join(rel, filepath.Base(absOut)))
+            sel := opts.Select
+            opts.Select = func(e streamline_core.Entry) bool {
+                if e.Name == self || strings.HasPrefix(e.Name, prefix) {
+                    return false
+                }
+                return sel == nil || sel(e)
+            }
+        }
+    }
+
+    f, err := os.CreateTemp(filepath.Dir(p.OutPath), "."+filepath.Base(p.OutPath)+".*.partial")
+    if err != nil {
+        return nil, fmt.Errorf("create output: %w", err)
+    }
+    committed := false
+    defer func() {
+        if !committed {
+            f.Close()
+            os.Remove(f.Name())
+        }
+    }()
+    counter := &countingWriter{w: f}
+    pp := *p
+    pp.Options = opts
+    summary, err := pp.write(ctx, src, counter, p.format(p.OutPath))
+    if err != nil {
+        return &PackResult{Summary: summary}, err
+    }
+    if err := f.Close(); err != nil {
+        return &PackResult{Summary: summary}, fmt.Errorf("write output: %w", err)
+    }
+    if err := os.Rename(f.Name(), p.OutPath); err != nil {
+        return &PackResult{Summary: summary}, fmt.Errorf("rename into place: %w", err)
+    }
+    committed = true
+    return &PackResult{Path: p.OutPath, Bytes: counter.n, Summary: summary}, nil
+}
+
+func (p *Packer) write(ctx context.Context, src streamline_core.PackSource, w io.Writer, format streamline_core.Format) (*streamline_core.ExtractSummary, error) {
+    aw, err := streamline_core.NewArchiveWriter(w, format, p.Level)
+    if err != nil {
+        return nil, err
+    }
+    summary, err := streamline_core.Pack(ctx, src, aw, p.Options)
+    if err != nil {
+        return summary, err
+    }
+    return summary, aw.Close()
+}
+
+func (p *Packer) format(name string) streamline_core.Format {
+    if p.Format != "" {
+        return p.Format
+    }
+    return streamline_core.PackFormatForName(name)
+}
+
+// uploadName is Name, or the source's name with the format's extension.
+func (p *Packer) uploadName(ctx context.Context, svc *drive.Service) (string, error) {
+    if p.Name != "" {
+        return p.Name, nil
+    }
+    base := ""
+    if p.Dir != "" {
+        abs, err := filepath.Abs(p.Dir)
+        if err != nil {
+            return "", err
+        }
+        base = filepath.Base(abs)
+    } else {
+        folder, err := svc.Files.Get(p.FolderID).Fields("name").Context(ctx).Do()
+        if err != nil {
+            return "", fmt.Errorf("get folder %s: %w", p.FolderID, err)
+        }
+        base = folder.Name
+    }
+    format := p.Format
+    if format == "" {
+        format = streamline_core.FormatZip
+    }
+    return base + "." + string(format), nil
+}
+
+func packMimeType(format streamline_core.Format) string {
+    if format == streamline_core.FormatTarGz {
+        return "application/gzip"
+    }
+    return "application/zip"
+}
+
+type countingWriter struct {
+    w io.Writer
+    n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    c.n += int64(n)
+    return n, err
+}
@@ -0,0 +1,159 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// RetryPolicy controls how transient failures of remote reads are retried.
+// Delays grow exponentially from BaseDelay up to MaxDelay with full jitter;
+// a server-supplied Retry-After always takes precedence.
+type RetryPolicy struct {
+    MaxAttempts int
+    BaseDelay   time.Duration
+    MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy fills in the fields a RetryPolicy leaves zero. Callers
+// with their own settings pass them in a RetryPolicy rather than changing it.
+var DefaultRetryPolicy = RetryPolicy{
+    MaxAttempts: 5,
+    BaseDelay:   500 * time.Millisecond,
+    MaxDelay:    30 * time.Second,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+    if p.MaxAttempts <= 0 {
+        p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+    }
+    if p.BaseDelay <= 0 {
+        p.BaseDelay = DefaultRetryPolicy.BaseDelay
+    }
+    if p.MaxDelay <= 0 {
+        p.MaxDelay = DefaultRetryPolicy.MaxDelay
+    }
+    return p
+}
+
+// Do runs op until it succeeds, fails with a non-retryable error, runs out
+// of attempts, or ctx is cancelled. The last error from op is returned.
+func (p RetryPolicy) Do(ctx context.Context, op func() error) error {
+    p = p.withDefaults()
+    var err error
+    for attempt := 0; attempt < p.MaxAttempts; attempt++ {
+        if cerr := ctx.Err(); cerr != nil {
+            if err == nil {
+                err = cerr
+            }
+            return err
+        }
+        if err = op(); err == nil || !IsRetryable(err) {
+            return err
+        }
+        if attempt == p.MaxAttempts-1 {
+            break
+        }
+        delay, ok := retryAfter(err)
+        if !ok {
+            delay = p.backoff(attempt)
+        }
+        t := time.NewTimer(delay)
+        select {
+        case <-ctx.Done():
+            t.Stop()
+            return err
+        case <-t.C:
+        }
+    }
+    return err
+}
+
+// backoff returns a full-jitter delay for the given zero-based attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+    d := p.MaxDelay
+    if attempt < 32 {
+        if exp := p.BaseDelay << uint(attempt); exp > 0 && exp < p.MaxDelay {
+            d = exp
+        }
+    }
+    return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// IsRetryable reports whether err looks transient: rate limiting, a server
+// error, or a dropped connection. Context cancellation is never retryable.
+func IsRetryable(err error) bool {
+    if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+        errors.Is(err, ErrAddressNotAllowed) {
+        return false
+    }
+    var gerr *googleapi.Error
+    if errors.As(err, &gerr) {
+        switch {
+        case gerr.Code == http.StatusTooManyRequests, gerr.Code >= 500:
+            return true
+        case gerr.Code == http.StatusForbidden:
+            for _, item := range gerr.Errors {
+                if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+                    return true
+                }
+            }
+        }
+        return false
+    }
+    if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) ||
+        errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+        return true
+    }
+    // The server hung up before sending a response.
+    var uerr *url.Error
+    if errors.As(err, &uerr) && errors.Is(uerr.Err, io.EOF) {
+        return true
+    }
+    var nerr net.Error
+    if errors.As(err, &nerr) && nerr.Timeout() {
+        return true
+    }
+    var oerr *net.OpError
+    return errors.As(err, &oerr)
+}
+
+// retryAfter extracts a Retry-After delay from an API error, if present.
+func retryAfter(err error) (time.Duration, bool) {
+    var gerr *googleapi.Error
+    if !errors.As(err, &gerr) || gerr.Header == nil {
+        return 0, false
+    }
+    return parseRetryAfter(gerr.Header.Get("Retry-After"))
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: delta-seconds and
+// an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+    if v == "" {
+        return 0, false
+    }
+    if secs, err := strconv.Atoi(v); err == nil {
+        if secs < 0 {
+            secs = 0
+        }
+        return time.Duration(secs) * time.Second, true
+    }
+    if t, err := http.ParseTime(v); err == nil {
+        d := time.Until(t)
+        if d < 0 {
+            d = 0
+        }
+        return d, true
+    }
+    return 0, false
+}
@@ -3,6 +3,7 @@ package downloader
 import (
 	"context"
 	"fmt"
+	"io"
 
 	"github.com/anacrolix/torrent"
 	"google.golang.org/api/drive/v3"
@@ -11,7 +12,9 @@ import (
 // TorrentDownloader implements Downloader for magnet links or .torrent files.
 type TorrentDownloader struct {
     MagnetURI string
-    Name      string // optional override for Drive filename
+    Name      string        // optional override for Drive filename
+    Upload    UploadOptions // optional: chunking, resume and progress for the upload
+    Checksum  Checksum      // optional: expected digest; a mismatch deletes the upload
 }
 
 func (t *TorrentDownloader) DownloadAndUpload(ctx context.Context, svc *drive.Service, targetFolderID string) (string, error) {
@@ -46,14 +49,27 @@ func (t *TorrentDownloader) DownloadAndUpload(ctx context.Context, svc *drive.Se
         Parents: []string{targetFolderID},
     }
 
-    // Stream torrent data into Drive upload
+    // Stream torrent data into Drive upload; a resumed upload seeks past
+    // what Drive already holds.
     reader := tor.NewReader()
     defer reader.Close()
+    open := func(ctx context.Context, offset int64) (io.ReadCloser, error) {
+        reader.SetContext(ctx)
+        if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+            return nil, fmt.Errorf("seek torrent to byte %d: %w", offset, err)
+        }
+        return io.NopCloser(reader), nil
+    }
 
-    created, err := svc.Files.Create(f).Media(reader).Do()
+    key := fmt.Sprintf("torrent\x00%s\x00%s\x00%s", tor.InfoHash().HexString(), targetFolderID, filename)
+    digest := newUploadDigest(t.Checksum.Digest != "")
+    created, err := upload(ctx, svc, f, key, tor.Length(), open, t.Upload, digest)
     if err != nil {
-        return "", fmt.Errorf("upload to Drive failed: %w", err)
+        return "", err
+    }
+    if err := checkUpload(ctx, svc, created, digest, t.Checksum, "", t.Upload.Retry); err != nil {
+        return "", err
     }
 
     return created.Id, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,421 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// DefaultUploadChunkSize is the resumable upload chunk size used when
+// UploadOptions.ChunkSize is zero. Each chunk is held in memory until Drive
+// acknowledges it.
+const DefaultUploadChunkSize = 16 << 20
+
+// uploadChunkAlign is the granularity Drive requires of every chunk but the
+// last.
+const uploadChunkAlign = 256 << 10
+
+// UploadProgress is called after each chunk Drive acknowledges, with the
+// bytes stored so far; total is -1 while the size is unknown.
+type UploadProgress func(sent, total int64)
+
+// UploadOptions controls how downloaders upload into Drive.
+type UploadOptions struct {
+    // Client sends the upload requests and must be authorized for Drive,
+    // like the client the service was built with. Without one, uploads use
+    // the API library's resumable upload, which retries within the process
+    // but cannot pick up after a restart.
+    Client *http.Client
+    // ChunkSize is rounded up to a multiple of 256 KiB; zero means
+    // DefaultUploadChunkSize.
+    ChunkSize int64
+    // SessionDir keeps the session URI of each unfinished upload so a later
+    // run of the same transfer resumes where this one stopped. Empty keeps
+    // sessions for the life of the upload only.
+    SessionDir string
+    Retry      RetryPolicy
+    OnProgress UploadProgress
+}
+
+func (o UploadOptions) chunkSize() int64 {
+    n := o.ChunkSize
+    if n <= 0 {
+        n = DefaultUploadChunkSize
+    }
+    return (n + uploadChunkAlign - 1) / uploadChunkAlign * uploadChunkAlign
+}
+
+// uploadSource opens the content being uploaded, offset bytes in.
+type uploadSource func(ctx context.Context, offset int64) (io.ReadCloser, error)
+
+// uploadSession is what SessionDir keeps for an unfinished upload.
+type uploadSession struct {
+    URI     string    `json:"uri"`
+    Key     string    `json:"key"`
+    Size    int64     `json:"size"`
+    Started time.Time `json:"started"`
+    // Hash is the SHA-256 state over the first Hashed bytes uploaded.
+    Hash   []byte `json:"hash,omitempty"`
+    Hashed int64  `json:"hashed,omitempty"`
+}
+
+// errSessionGone means Drive no longer knows an upload session; sessions
+// expire a week after they start.
+var errSessionGone = errors.New("upload session expired")
+
+// upload creates f in Drive with the content open supplies. size is -1
+// when unknown. key identifies the transfer across runs: the same key and
+// size resume a persisted session. digest, if set, is fed the content.
+func upload(ctx context.Context, svc *drive.Service, f *drive.File, key string, size int64, open uploadSource, opts UploadOptions, digest *uploadDigest) (*drive.File, error) {
+    if opts.Client == nil {
+        return uploadInProcess(ctx, svc, f, size, open, opts, digest)
+    }
+    u := &resumableUpload{svc: svc, file: f, key: key, size: size, open: open, opts: opts, digest: digest}
+    return u.run(ctx)
+}
+
+// uploadInProcess hands the whole transfer to the API library.
+func uploadInProcess(ctx context.Context, svc *drive.Service, f *drive.File, size int64, open uploadSource, opts UploadOptions, digest *uploadDigest) (*drive.File, error) {
+    rc, err := open(ctx, 0)
+    if err != nil {
+        return nil, err
+    }
+    defer rc.Close()
+    var r io.Reader = rc
+    if digest != nil {
+        r = io.TeeReader(rc, digest)
+    }
+    call := svc.Files.Create(f).Context(ctx)
+    media := []googleapi.MediaOption{googleapi.ChunkSize(int(opts.chunkSize()))}
+    if f.MimeType != "" {
+        media = append(media, googleapi.ContentType(f.MimeType))
+    }
+    call.Media(r, media...)
+    if opts.OnProgress != nil {
+        call.ProgressUpdater(func(current, _ int64) { opts.OnProgress(current, size) })
+    }
+    created, err := call.Do()
+    if err != nil {
+        return nil, fmt.Errorf("upload to Drive failed: %w", err)
+    }
+    return created, nil
+}
+
+// resumableUpload speaks Drive's resumable upload protocol directly, so the
+// session URI can outlive the process.
+type resumableUpload struct {
+    svc  *drive.Service
+    file *drive.File
+    key  string
+    size int64
+    open uploadSource
+    opts UploadOptions
+
+    digest  *uploadDigest
+    uri     string
+    started time.Time
+}
+
+func (u *resumableUpload) run(ctx context.Context) (*drive.File, error) {
+    var committed int64
+    if s, ok := u.loadSession(); ok {
+        u.uri, u.started = s.URI, s.Started
+        u.digest.restore(s.Hash, s.Hashed)
+        var done *drive.File
+        err := u.opts.Retry.Do(ctx, func() error {
+            var err error
+            committed, done, err = u.put(ctx, nil, 0, u.size)
+            return err
+        })
+        switch {
+        case done != nil:
+            // An earlier run finished; the digest may lack its last chunk.
+            if err := u.catchUp(ctx, committed); err != nil {
+                return nil, err
+            }
+            u.removeSession()
+            u.progress(committed)
+            return done, nil
+        case errors.Is(err, errSessionGone):
+            u.uri, committed = "", 0
+            u.digest.restore(nil, 0)
+        case err != nil:
+            return nil, fmt.Errorf("resume upload: %w", err)
+        }
+    }
+    if u.uri == "" {
+        if err := u.opts.Retry.Do(ctx, func() error { return u.start(ctx) }); err != nil {
+            return nil, fmt.Errorf("start upload: %w", err)
+        }
+        u.started = time.Now()
+        u.saveSession()
+    }
+    u.progress(committed)
+
+    from := u.digest.resumeAt(committed)
+    rc, err := u.open(ctx, from)
+    if err != nil {
+        return nil, err
+    }
+    defer rc.Close()
+    if err := u.digest.catchUp(rc, committed); err != nil {
+        return nil, err
+    }
+
+    buf := make([]byte, u.opts.chunkSize())
+    n, eof, stalls := 0, false, 0
+    for {
+        // Every chunk but the last must fill the buffer.
+        if !eof && n < len(buf) {
+            m, err := io.ReadFull(rc, buf[n:])
+            n += m
+            if err == io.EOF || err == io.ErrUnexpectedEOF {
+                eof = true
+            } else if err != nil {
+                return nil, fmt.Errorf("read source: %w", err)
+            }
+        }
+        end := committed + int64(n)
+        total := u.size
+        switch {
+        case u.size >= 0 && end > u.size:
+            return nil, fmt.Errorf("source is longer than the expected %d bytes", u.size)
+        case eof && u.size >= 0 && end != u.size:
+            return nil, fmt.Errorf("source ended at %d bytes; expected %d", end, u.size)
+        case eof:
+            total = end
+        }
+
+        // A failed request may still have stored part of the chunk, so a
+        // retry first asks how much arrived and sends only the rest.
+        var sent int64
+        var done *drive.File
+        retrying := false
+        err := u.opts.Retry.Do(ctx, func() error {
+            from := committed
+            if retrying {
+                var err error
+                if sent, done, err = u.put(ctx, nil, 0, total); done != nil || err != nil {
+                    return err
+                }
+                if sent < committed || sent > end {
+                    return fmt.Errorf("drive reports %d bytes stored; expected %d to %d", sent, committed, end)
+                }
+                if from = sent; from == end && !eof {
+                    return nil
+                }
+            }
+            retrying = true
+            var err error
+            sent, done, err = u.put(ctx, buf[from-committed:n], from, total)
+            return err
+        })
+        if errors.Is(err, errSessionGone) {
+            u.removeSession()
+        }
+        if err != nil {
+            return nil, fmt.Errorf("upload to Drive failed: %w", err)
+        }
+        if done != nil {
+            if u.digest != nil {
+                u.digest.Write(buf[:n])
+            }
+            u.removeSession()
+            u.progress(total)
+            return done, nil
+        }
+        if sent < committed || sent > end {
+            return nil, fmt.Errorf("upload to Drive failed: %d bytes stored; expected %d to %d", sent, committed, end)
+        }
+        if eof && sent == end {
+            return nil, fmt.Errorf("upload to Drive failed: all %d bytes stored but the file was not created", end)
+        }
+        if sent == committed {
+            if stalls++; stalls >= u.opts.Retry.withDefaults().MaxAttempts {
+                return nil, fmt.Errorf("upload to Drive failed: no progress at %d bytes", committed)
+            }
+        } else {
+            stalls = 0
+        }
+        if u.digest != nil {
+            u.digest.Write(buf[:sent-committed])
+        }
+        copy(buf, buf[sent-committed:n])
+        n -= int(sent - committed)
+        committed = sent
+        if u.digest != nil {
+            // Keep the hash state in step with what Drive has stored.
+            u.saveSession()
+        }
+        u.progress(committed)
+    }
+}
+
+// start opens a session and records its URI.
+func (u *resumableUpload) start(ctx context.Context) error {
+    meta, err := json.Marshal(u.file)
+    if err != nil {
+        return err
+    }
+    endpoint := googleapi.ResolveRelative(u.svc.BasePath, "/upload/drive/v3/files") +
+        "?uploadType=resumable&alt=json&prettyPrint=false&fields=id,name,size,mimeType"
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(meta))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+    if u.file.MimeType != "" {
+        req.Header.Set("X-Upload-Content-Type", u.file.MimeType)
+    }
+    if u.size >= 0 {
+        req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(u.size, 10))
+    }
+    resp, err := u.opts.Client.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    if err := googleapi.CheckResponse(resp); err != nil {
+        return err
+    }
+    if u.uri = resp.Header.Get("Location"); u.uri == "" {
+        return fmt.Errorf("drive returned no upload session")
+    }
+    return nil
+}
+
+// put sends data as the bytes starting at off, or with no data asks how
+// far the session has got. It returns the bytes Drive has stored, or the
+// file once the upload is complete.
+func (u *resumableUpload) put(ctx context.Context, data []byte, off, total int64) (int64, *drive.File, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.uri, bytes.NewReader(data))
+    if err != nil {
+        return 0, nil, err
+    }
+    req.ContentLength = int64(len(data))
+    size := "*"
+    if total >= 0 {
+        size = strconv.FormatInt(total, 10)
+    }
+    if len(data) == 0 {
+        req.Header.Set("Content-Range", "bytes */"+size)
+    } else {
+        req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", off, off+int64(len(data))-1, size))
+    }
+    resp, err := u.opts.Client.Do(req)
+    if err != nil {
+        return 0, nil, err
+    }
+    defer resp.Body.Close()
+
+    switch {
+    case resp.StatusCode == http.StatusPermanentRedirect:
+        // "Resume Incomplete": Range says what is stored, if anything.
+        stored, err := storedBytes(resp.Header.Get("Range"))
+        return stored, nil, err
+    case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+        return 0, nil, errSessionGone
+    }
+    if err := googleapi.CheckResponse(resp); err != nil {
+        return 0, nil, err
+    }
+    var f drive.File
+    if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+        return 0, nil, fmt.Errorf("decode uploaded file: %w", err)
+    }
+    return total, &f, nil
+}
+
+// catchUp feeds the digest the bytes it is missing up to committed, for an
+// upload that has nothing left to send.
+func (u *resumableUpload) catchUp(ctx context.Context, committed int64) error {
+    from := u.digest.resumeAt(committed)
+    if u.digest == nil || from >= committed {
+        return nil
+    }
+    rc, err := u.open(ctx, from)
+    if err != nil {
+        return err
+    }
+    defer rc.Close()
+    return u.digest.catchUp(rc, committed)
+}
+
+// storedBytes reads how many bytes a session holds from the Range header
+// of a 308 response: "bytes=0-<last>", or nothing when none are stored.
+func storedBytes(rng string) (int64, error) {
+    if rng == "" {
+        return 0, nil
+    }
+    last, ok := strings.CutPrefix(rng, "bytes=0-")
+    n, err := strconv.ParseInt(last, 10, 64)
+    if !ok || err != nil || n < 0 {
+        return 0, fmt.Errorf("unexpected Range %q from Drive", rng)
+    }
+    return n + 1, nil
+}
+
+func (u *resumableUpload) progress(sent int64) {
+    if u.opts.OnProgress != nil {
+        u.opts.OnProgress(sent, u.size)
+    }
+}
+
+func (u *resumableUpload) sessionPath() string {
+    if u.opts.SessionDir == "" || u.key == "" {
+        return ""
+    }
+    sum := sha256.Sum256([]byte(u.key))
+    return filepath.Join(u.opts.SessionDir, hex.EncodeToString(sum[:16])+".json")
+}
+
+// loadSession finds a persisted session for this transfer. One recorded
+// for a different size belongs to content that has since changed.
+func (u *resumableUpload) loadSession() (uploadSession, bool) {
+    var s uploadSession
+    path := u.sessionPath()
+    if path == "" {
+        return s, false
+    }
+    data, err := os.ReadFile(path)
+    if err != nil || json.Unmarshal(data, &s) != nil || s.Key != u.key || s.Size != u.size || s.URI == "" {
+        return s, false
+    }
+    return s, true
+}
+
+// saveSession is best effort: failing to persist only costs the ability to
+// resume after a restart.
+func (u *resumableUpload) saveSession() {
+    path := u.sessionPath()
+    if path == "" {
+        return
+    }
+    s := uploadSession{URI: u.uri, Key: u.key, Size: u.size, Started: u.started}
+    s.Hash, s.Hashed = u.digest.state()
+    data, _ := json.Marshal(s)
+    if err := os.MkdirAll(filepath.Dir(path), 0o700); err == nil {
+        // The URI alone authorizes writes to the upload.
+        os.WriteFile(path, data, 0o600)
+    }
+}
+
+func (u *resumableUpload) removeSession() {
+    if path := u.sessionPath(); path != "" {
+        os.Remove(path)
+    }
+}
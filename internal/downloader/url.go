@@ -3,52 +3,84 @@ package downloader
 import (
 	"context"
 	"fmt"
-	"strings"
-
-	//"io"
+	"io"
 	"net/http"
 
 	"google.golang.org/api/drive/v3"
 )
 
-// URLDownloader implements Downloader for direct HTTP/HTTPS URLs.
+// URLDownloader implements Downloader for direct HTTP/HTTPS URLs. Servers
+// that answer range requests are read in parallel segments, each retried
+// and resumed on its own; others are read as a single stream.
 type URLDownloader struct {
-    URL  string
-    Name string // optional: desired filename in Drive
+    URL    string
+    Name   string        // optional: desired filename in Drive
+    Upload UploadOptions // optional: chunking, resume and progress for the upload
+
+    HTTP        HTTPOptions  // optional: headers, credentials, proxy and TLS for the source
+    Client      *http.Client // optional; replaces the client built from HTTP
+    Segments    int          // parallel range requests; 0 means DefaultURLSegments
+    SegmentSize int64        // bytes per range request; 0 means DefaultSegmentSize
+    Retry       RetryPolicy  // for the probe and each segment
+
+    Checksum        Checksum // optional: expected digest; a mismatch deletes the upload
+    ChecksumSidecar bool     // without Checksum, look for <URL>.sha256 or SHA256SUMS beside the URL
 }
 
 func (u *URLDownloader) DownloadAndUpload(ctx context.Context, svc *drive.Service, targetFolderID string) (string, error) {
-    resp, err := http.Get(u.URL)
-    if err != nil {
-        return "", fmt.Errorf("failed to fetch URL: %w", err)
+    client := u.Client
+    if client == nil {
+        var err error
+        if client, err = u.HTTP.client(u.URL); err != nil {
+            return "", err
+        }
     }
-    defer resp.Body.Close()
-
-    if resp.StatusCode != http.StatusOK {
-        return "", fmt.Errorf("bad status from URL: %s", resp.Status)
+    src, err := probeURL(ctx, client, u.URL, u.Retry)
+    if err != nil {
+        return "", err
     }
+    defer src.close()
+    src.segments, src.segSize = u.Segments, u.SegmentSize
 
-    // Decide filename
+    // Decide filename and type: the server's word first, then the content.
     filename := u.Name
     if filename == "" {
-        parts := strings.Split(u.URL, "/")
-        filename = parts[len(parts)-1]
-        if filename == "" {
-            filename = "downloaded_file"
-        }
+        filename = resolveFilename(src.header, src.finalURL, detectMimeType(src.header, src.head, ""))
     }
 
-    // Prepare Drive file metadata
+    // Prepare Drive file metadata, recording where the file came from
+    props, fits := sourceProperties(u.URL)
     f := &drive.File{
-        Name:    filename,
-        Parents: []string{targetFolderID},
+        Name:          filename,
+        Parents:       []string{targetFolderID},
+        MimeType:      detectMimeType(src.header, src.head, filename),
+        AppProperties: props,
+    }
+    if !fits {
+        f.Description = "Downloaded from " + redactURL(u.URL)
+    }
+
+    // The validator is part of the key, so an upload begun from an older
+    // version of the content is never resumed with bytes of a newer one.
+    key := fmt.Sprintf("url\x00%s\x00%s\x00%s\x00%s", u.URL, targetFolderID, filename, src.validator)
+    want, sidecar := u.Checksum, ""
+    if want.Digest == "" && u.ChecksumSidecar {
+        want, sidecar, _ = findSidecarChecksum(ctx, client, u.Retry, u.URL, filename)
     }
 
-    // Upload directly from response body
-    created, err := svc.Files.Create(f).Media(resp.Body).Do()
+    // Hash while uploading; the digest is checked and recorded once Drive
+    // has the whole file.
+    digest := newUploadDigest(want.Digest != "")
+    open := func(ctx context.Context, offset int64) (io.ReadCloser, error) {
+        return src.open(ctx, offset)
+    }
+    created, err := upload(ctx, svc, f, key, src.size, open, u.Upload, digest)
     if err != nil {
-        return "", fmt.Errorf("upload to Drive failed: %w", err)
+        return "", err
+    }
+    if err := checkUpload(ctx, svc, created, digest, want, sidecar, u.Upload.Retry); err != nil {
+        return "", err
     }
 
     return created.Id, nil
-}
\ No newline at end of file
+}
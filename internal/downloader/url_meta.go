@@ -0,0 +1,181 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fallbackFilename names downloads nothing else gives a name to.
+const fallbackFilename = "download"
+
+// maxFilenameBytes keeps names within what common filesystems accept, for
+// whoever syncs the file back down.
+const maxFilenameBytes = 255
+
+var (
+    extFilenameRe   = regexp.MustCompile(`(?i)(?:^|;)\s*filename\*\s*=\s*([^;]+)`)
+    plainFilenameRe = regexp.MustCompile(`(?i)(?:^|;)\s*filename\s*=\s*("(?:[^"\\]|\\.)*"|[^;]*)`)
+)
+
+// resolveFilename names a download after its Content-Disposition header
+// (preferring RFC 5987 filename*), else the last path segment of the URL
+// the request ended up at, else fallbackFilename with an extension for its
+// MIME type. The result is always a single, sanitized path element.
+func resolveFilename(header http.Header, finalURL, mimeType string) string {
+    if name := sanitizeFilename(dispositionFilename(header.Get("Content-Disposition"))); name != "" {
+        return name
+    }
+    if u, err := url.Parse(finalURL); err == nil {
+        if name := sanitizeFilename(path.Base(u.Path)); name != "" && name != "." {
+            return name
+        }
+    }
+    if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+        return fallbackFilename + exts[0]
+    }
+    return fallbackFilename
+}
+
+// dispositionFilename extracts the file name from a Content-Disposition
+// value. filename* wins over filename, as RFC 6266 asks; both UTF-8 and
+// ISO-8859-1 extended values are decoded. Malformed headers that servers
+// send anyway (unquoted names with spaces) are read leniently.
+func dispositionFilename(cd string) string {
+    if cd == "" {
+        return ""
+    }
+    if m := extFilenameRe.FindStringSubmatch(cd); m != nil {
+        if name, ok := decodeExtValue(strings.TrimSpace(m[1])); ok {
+            return name
+        }
+    }
+    if _, params, err := mime.ParseMediaType(cd); err == nil && params["filename"] != "" {
+        return params["filename"]
+    }
+    if m := plainFilenameRe.FindStringSubmatch(cd); m != nil {
+        v := strings.TrimSpace(m[1])
+        if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+            v = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1])
+        }
+        return v
+    }
+    return ""
+}
+
+// decodeExtValue decodes an RFC 5987 ext-value: charset'lang'pct-encoded.
+func decodeExtValue(v string) (string, bool) {
+    parts := strings.SplitN(strings.Trim(v, `"`), "'", 3)
+    if len(parts) != 3 {
+        return "", false
+    }
+    raw, err := url.PathUnescape(parts[2])
+    if err != nil {
+        return "", false
+    }
+    switch strings.ToLower(parts[0]) {
+    case "utf-8":
+        return raw, utf8.ValidString(raw)
+    case "iso-8859-1":
+        runes := make([]rune, len(raw))
+        for i := 0; i < len(raw); i++ {
+            runes[i] = rune(raw[i])
+        }
+        return string(runes), true
+    }
+    return "", false
+}
+
+// sanitizeFilename reduces name to something safe as a Drive file name and
+// on disk: no directories, control characters or characters Windows
+// rejects, no leading or trailing dots and spaces, at most
+// maxFilenameBytes. It returns "" if nothing usable is left.
+func sanitizeFilename(name string) string {
+    // Only the last element of a path-like name is the file's own.
+    if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+        name = name[i+1:]
+    }
+    name = strings.Map(func(r rune) rune {
+        switch {
+        case r == utf8.RuneError, unicode.IsControl(r):
+            return -1
+        case strings.ContainsRune(`<>:"|?*`, r):
+            return '_'
+        }
+        return r
+    }, name)
+    name = strings.Trim(name, ". ")
+    if len(name) > maxFilenameBytes {
+        // Keep the extension and cut the stem on a rune boundary.
+        ext := path.Ext(name)
+        if len(ext) > 16 {
+            ext = ""
+        }
+        stem := name[:maxFilenameBytes-len(ext)]
+        for !utf8.ValidString(stem) {
+            stem = stem[:len(stem)-1]
+        }
+        name = stem + ext
+    }
+    return name
+}
+
+// detectMimeType takes the server's Content-Type unless it is the generic
+// octet-stream, then sniffs head, then falls back to the name's extension.
+func detectMimeType(header http.Header, head []byte, filename string) string {
+    if mt, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil && !genericMimeType(mt) {
+        return mt
+    }
+    if len(head) > 0 {
+        if mt, _, err := mime.ParseMediaType(http.DetectContentType(head)); err == nil && !genericMimeType(mt) {
+            return mt
+        }
+    }
+    if mt, _, err := mime.ParseMediaType(mime.TypeByExtension(path.Ext(filename))); err == nil {
+        return mt
+    }
+    return "application/octet-stream"
+}
+
+func genericMimeType(mt string) bool {
+    return mt == "application/octet-stream" || mt == "binary/octet-stream" || mt == "application/unknown"
+}
+
+// maxAppProperty is Drive's limit on the bytes of an appProperties key and
+// value together.
+const maxAppProperty = 124
+
+// sourceProperties records where a download came from in appProperties.
+// Credentials, the query and the fragment are dropped. A URL too long for one property is
+// recorded by its SHA-256 instead, and the caller keeps the full URL in the
+// description.
+func sourceProperties(rawURL string) (props map[string]string, fits bool) {
+    clean := redactURL(rawURL)
+    if len("sourceUrl")+len(clean) <= maxAppProperty {
+        return map[string]string{"sourceUrl": clean}, true
+    }
+    sum := sha256.Sum256([]byte(clean))
+    return map[string]string{"sourceUrlSha256": hex.EncodeToString(sum[:])}, false
+}
+
+// redactURL drops any user:password@, query and fragment from a URL. Signed
+// URLs and API links carry their secrets in the query, and what is left is
+// stored in Drive for good.
+func redactURL(rawURL string) string {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        // Too mangled to tell what is secret; record nothing of it.
+        return ""
+    }
+    u.User = nil
+    u.RawQuery, u.ForceQuery = "", false
+    u.Fragment, u.RawFragment = "", ""
+    return u.String()
+}
@@ -0,0 +1,461 @@
+package extract_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	streamline_core "Streamline/cmd/streamline_core"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var archiveFiles = map[string]string{
+    "top.txt":        "top level",
+    "docs/readme.md": "# readme",
+}
+
+func buildTar(t *testing.T) []byte {
+    t.Helper()
+    buf := new(bytes.Buffer)
+    tw := tar.NewWriter(buf)
+    tw.WriteHeader(&tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0o755})
+    for _, name := range []string{"top.txt", "docs/readme.md"} {
+        body := archiveFiles[name]
+        tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(body))})
+        tw.Write([]byte(body))
+    }
+    tw.Close()
+    return buf.Bytes()
+}
+
+func compressWith(t *testing.T, raw []byte, wrap func(io.Writer) (io.WriteCloser, error)) []byte {
+    t.Helper()
+    buf := new(bytes.Buffer)
+    w, err := wrap(buf)
+    if err != nil {
+        t.Fatalf("compressor: %v", err)
+    }
+    w.Write(raw)
+    w.Close()
+    return buf.Bytes()
+}
+
+func buildZip(t *testing.T) []byte {
+    t.Helper()
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+    for _, name := range []string{"top.txt", "docs/readme.md"} {
+        w, _ := zw.Create(name)
+        w.Write([]byte(archiveFiles[name]))
+    }
+    zw.Close()
+    return buf.Bytes()
+}
+
+func TestArchiveFormats(t *testing.T) {
+    raw := buildTar(t)
+    cases := []struct {
+        format streamline_core.Format
+        data   []byte
+    }{
+        {streamline_core.FormatZip, buildZip(t)},
+        {streamline_core.FormatTar, raw},
+        {streamline_core.FormatTarGz, compressWith(t, raw, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })},
+        {streamline_core.FormatTarXz, compressWith(t, raw, func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) })},
+        {streamline_core.FormatTarZst, compressWith(t, raw, func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) })},
+        {streamline_core.FormatRar, buildRar(t)},
+        {streamline_core.Format7z, build7z(t, "")},
+    }
+
+    for _, tc := range cases {
+        t.Run(string(tc.format), func(t *testing.T) {
+            a, err := streamline_core.NewArchive(bytes.NewReader(tc.data), int64(len(tc.data)))
+            if err != nil {
+                t.Fatalf("NewArchive failed: %v", err)
+            }
+            defer a.Close()
+            if a.Format() != tc.format {
+                t.Errorf("Format() = %s; want %s", a.Format(), tc.format)
+            }
+
+            entries, err := a.List()
+            if err != nil {
+                t.Fatalf("List failed: %v", err)
+            }
+            found := 0
+            for _, e := range entries {
+                if _, ok := archiveFiles[e.Name]; ok {
+                    found++
+                }
+            }
+            if found != len(archiveFiles) {
+                t.Errorf("List found %d of %d files: %+v", found, len(archiveFiles), entries)
+            }
+
+            rc, err := a.Open("docs/readme.md")
+            if err != nil {
+                t.Fatalf("Open failed: %v", err)
+            }
+            body, _ := io.ReadAll(rc)
+            rc.Close()
+            if string(body) != archiveFiles["docs/readme.md"] {
+                t.Errorf("Open content = %q", body)
+            }
+
+            outDir := t.TempDir()
+            summary, err := a.Extract(context.Background(), outDir, streamline_core.ExtractOptions{
+                Select: func(e streamline_core.Entry) bool {
+                    return streamline_core.ShouldExtract(e.Name, "*.md", "")
+                },
+            })
+            if err != nil {
+                t.Fatalf("Extract failed: %v", err)
+            }
+            if summary.Extracted != 1 {
+                t.Errorf("Extracted = %d; want 1 (%+v)", summary.Extracted, summary)
+            }
+            got, err := os.ReadFile(filepath.Join(outDir, "docs", "readme.md"))
+            if err != nil || string(got) != archiveFiles["docs/readme.md"] {
+                t.Errorf("extracted readme = %q, %v", got, err)
+            }
+            if _, err := os.Stat(filepath.Join(outDir, "top.txt")); !os.IsNotExist(err) {
+                t.Errorf("filtered file top.txt was extracted")
+            }
+        })
+    }
+}
+
+func TestDetectFormatRejectsUnknown(t *testing.T) {
+    data := []byte("definitely not an archive")
+    if _, err := streamline_core.DetectFormat(bytes.NewReader(data), int64(len(data))); err == nil {
+        t.Errorf("expected an error for unknown data")
+    }
+}
+
+func TestListEntries(t *testing.T) {
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+    w, _ := zw.CreateHeader(&zip.FileHeader{Name: "notes.txt", Method: zip.Deflate, Comment: "first draft"})
+    w.Write([]byte("some notes"))
+    w, _ = zw.CreateHeader(&zip.FileHeader{Name: "raw.bin", Method: zip.Store})
+    w.Write([]byte("raw"))
+    zw.Close()
+    path := filepath.Join(t.TempDir(), "list.zip")
+    os.WriteFile(path, buf.Bytes(), 0o644)
+
+    entries, err := streamline_core.ListEntries(path)
+    if err != nil {
+        t.Fatalf("ListEntries failed: %v", err)
+    }
+    if len(entries) != 2 {
+        t.Fatalf("got %d entries; want 2", len(entries))
+    }
+    notes, raw := entries[0], entries[1]
+    if notes.Method != "deflate" || notes.Comment != "first draft" || notes.Size != 10 {
+        t.Errorf("notes.txt = method %q, comment %q, size %d", notes.Method, notes.Comment, notes.Size)
+    }
+    if !notes.HasCRC || notes.CRC32 != crc32.ChecksumIEEE([]byte("some notes")) {
+        t.Errorf("notes.txt crc32 = %08x", notes.CRC32)
+    }
+    if raw.Method != "store" || raw.CompressedSize != 3 {
+        t.Errorf("raw.bin = method %q, compressed %d", raw.Method, raw.CompressedSize)
+    }
+}
+
+func TestOpenEntry(t *testing.T) {
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+    zw.Create("dir/")
+    w, _ := zw.CreateHeader(&zip.FileHeader{Name: "dir/stored.txt", Method: zip.Store})
+    w.Write([]byte("0123456789"))
+    w, _ = zw.CreateHeader(&zip.FileHeader{Name: "packed.txt", Method: zip.Deflate})
+    w.Write(bytes.Repeat([]byte("abc"), 1000))
+    zw.Close()
+    path := filepath.Join(t.TempDir(), "entries.zip")
+    os.WriteFile(path, buf.Bytes(), 0o644)
+
+    _, rc, err := streamline_core.OpenEntry(path, "dir/stored.txt", streamline_core.Limits{})
+    if err != nil {
+        t.Fatalf("OpenEntry(stored) failed: %v", err)
+    }
+    rs, ok := rc.(io.ReadSeeker)
+    if !ok {
+        t.Fatalf("stored entry is not seekable")
+    }
+    rs.Seek(4, io.SeekStart)
+    if got, _ := io.ReadAll(rs); string(got) != "456789" {
+        t.Errorf("stored entry from offset 4 = %q", got)
+    }
+    rc.Close()
+
+    e, rc, err := streamline_core.OpenEntry(path, "packed.txt", streamline_core.Limits{})
+    if err != nil {
+        t.Fatalf("OpenEntry(packed) failed: %v", err)
+    }
+    if _, ok := rc.(io.Seeker); ok {
+        t.Errorf("compressed entry claims to be seekable")
+    }
+    if got, _ := io.ReadAll(rc); int64(len(got)) != e.Size {
+        t.Errorf("read %d bytes; want %d", len(got), e.Size)
+    }
+    rc.Close()
+
+    _, rc, err = streamline_core.OpenEntry(path, "packed.txt", streamline_core.Limits{MaxEntryBytes: 100})
+    if err != nil {
+        t.Fatalf("OpenEntry(limited) failed: %v", err)
+    }
+    var lerr *streamline_core.LimitError
+    if _, err := io.ReadAll(rc); !errors.As(err, &lerr) {
+        t.Errorf("reading past MaxEntryBytes: err = %v; want a LimitError", err)
+    }
+    rc.Close()
+
+    if _, _, err := streamline_core.OpenEntry(path, "dir/", streamline_core.Limits{}); !errors.Is(err, streamline_core.ErrIsDir) {
+        t.Errorf("directory: err = %v; want ErrIsDir", err)
+    }
+    if _, _, err := streamline_core.OpenEntry(path, "missing.txt", streamline_core.Limits{}); !errors.Is(err, os.ErrNotExist) {
+        t.Errorf("missing entry: err = %v; want ErrNotExist", err)
+    }
+}
+
+func TestExtractRejectsTraversal(t *testing.T) {
+    buf := new(bytes.Buffer)
+    tw := tar.NewWriter(buf)
+    tw.WriteHeader(&tar.Header{Name: "../escape.txt", Typeflag: tar.TypeReg, Mode: 0o644, Size: 1})
+    tw.Write([]byte("x"))
+    tw.Close()
+
+    a, err := streamline_core.NewArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+    if err != nil {
+        t.Fatalf("NewArchive failed: %v", err)
+    }
+    parent := t.TempDir()
+    outDir := filepath.Join(parent, "out")
+    a.Extract(context.Background(), outDir, streamline_core.ExtractOptions{ContinueOnError: true})
+    if _, err := os.Stat(filepath.Join(parent, "escape.txt")); !os.IsNotExist(err) {
+        t.Errorf("entry escaped the output directory")
+    }
+}
+
+func TestExtractReportsUnsafeTarNames(t *testing.T) {
+    buf := new(bytes.Buffer)
+    tw := tar.NewWriter(buf)
+    for _, name := range []string{"../../etc/x", "/abs.txt", "docs/../../up.txt", "./ok.txt"} {
+        tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: 1})
+        tw.Write([]byte("x"))
+    }
+    tw.Close()
+
+    a, err := streamline_core.NewArchive(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+    if err != nil {
+        t.Fatalf("NewArchive failed: %v", err)
+    }
+    outDir := t.TempDir()
+    summary, err := a.Extract(context.Background(), outDir, streamline_core.ExtractOptions{ContinueOnError: true})
+    if err != nil {
+        t.Fatalf("Extract failed: %v", err)
+    }
+    if summary.Extracted != 1 || len(summary.Errors) != 3 {
+        t.Fatalf("summary = %+v; want ok.txt extracted and three illegal paths", summary)
+    }
+    for _, e := range summary.Errors {
+        if !strings.Contains(e.Error(), "illegal path") {
+            t.Errorf("error = %v; want an illegal path", e)
+        }
+    }
+    for _, stray := range []string{"etc/x", "abs.txt", "up.txt"} {
+        if _, err := os.Stat(filepath.Join(outDir, stray)); !os.IsNotExist(err) {
+            t.Errorf("%s was written under the output directory", stray)
+        }
+    }
+}
+
+// rar5Header frames one RAR5 block: CRC32, size and the type/flags/body.
+func rar5Header(fields ...uint64) []byte {
+    var body []byte
+    for _, f := range fields {
+        body = binary.AppendUvarint(body, f)
+    }
+    return rar5Frame(body)
+}
+
+func rar5Frame(body []byte) []byte {
+    sized := append(binary.AppendUvarint(nil, uint64(len(body))), body...)
+    return append(binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(sized)), sized...)
+}
+
+// rar5File encodes a stored (uncompressed) file or directory header
+// followed by its data. For a file split across volumes data is this
+// volume's part, size the full unpacked size and crc the whole file's.
+// A negative size marks the unpacked size unknown.
+func rar5File(name string, data []byte, size int, crc uint32, dir bool, splitFlags uint64) []byte {
+    var body []byte
+    fileFlags := uint64(0x0004) // CRC32 present
+    if dir {
+        fileFlags = 0x0001
+    }
+    if size < 0 {
+        fileFlags |= 0x0008
+        size = 0
+    }
+    body = binary.AppendUvarint(body, 2) // file header
+    body = binary.AppendUvarint(body, 0x0002|splitFlags) // data area present
+    body = binary.AppendUvarint(body, uint64(len(data))) // data size
+    body = binary.AppendUvarint(body, fileFlags)
+    body = binary.AppendUvarint(body, uint64(size)) // unpacked size
+    body = binary.AppendUvarint(body, 0o644) // attributes
+    if !dir {
+        body = binary.LittleEndian.AppendUint32(body, crc)
+    }
+    body = binary.AppendUvarint(body, 0) // stored, version 0
+    body = binary.AppendUvarint(body, 1) // unix host
+    body = binary.AppendUvarint(body, uint64(len(name)))
+    body = append(body, name...)
+    return append(rar5Frame(body), data...)
+}
+
+var rar5Signature = []byte("Rar!\x1a\x07\x01\x00")
+
+func buildRar(t *testing.T) []byte {
+    t.Helper()
+    out := append([]byte{}, rar5Signature...)
+    out = append(out, rar5Header(1, 0, 0)...) // main header
+    out = append(out, rar5File("docs", nil, 0, 0, true, 0)...)
+    for _, name := range []string{"top.txt", "docs/readme.md"} {
+        data := []byte(archiveFiles[name])
+        out = append(out, rar5File(name, data, len(data), crc32.ChecksumIEEE(data), false, 0)...)
+    }
+    return append(out, rar5Header(5, 0, 0)...) // end of archive
+}
+
+func TestRarMultiVolume(t *testing.T) {
+    body := []byte(archiveFiles["docs/readme.md"])
+    const (
+        volumeFlag    = 0x0001
+        volNumberFlag = 0x0002
+        splitAfter    = 0x0010
+        splitBefore   = 0x0008
+    )
+    crc := crc32.ChecksumIEEE(body)
+    part1 := append([]byte{}, rar5Signature...)
+    part1 = append(part1, rar5Header(1, 0, volumeFlag)...)
+    part1 = append(part1, rar5File("docs/readme.md", body[:3], len(body), crc, false, splitAfter)...)
+    part1 = append(part1, rar5Header(5, 0, 0x0001)...) // more volumes follow
+
+    part2 := append([]byte{}, rar5Signature...)
+    part2 = append(part2, rar5Header(1, 0, volumeFlag|volNumberFlag, 1)...)
+    part2 = append(part2, rar5File("docs/readme.md", body[3:], len(body), crc, false, splitBefore)...)
+    part2 = append(part2, rar5Header(5, 0, 0)...)
+
+    dir := t.TempDir()
+    os.WriteFile(filepath.Join(dir, "set.part1.rar"), part1, 0o644)
+    os.WriteFile(filepath.Join(dir, "set.part2.rar"), part2, 0o644)
+
+    // Naming a later volume still opens the whole set.
+    a, err := streamline_core.OpenArchive(filepath.Join(dir, "set.part2.rar"))
+    if err != nil {
+        t.Fatalf("OpenArchive failed: %v", err)
+    }
+    defer a.Close()
+    if a.Format() != streamline_core.FormatRar {
+        t.Fatalf("Format() = %s; want rar", a.Format())
+    }
+    outDir := t.TempDir()
+    if _, err := a.Extract(context.Background(), outDir, streamline_core.ExtractOptions{}); err != nil {
+        t.Fatalf("Extract failed: %v", err)
+    }
+    got, err := os.ReadFile(filepath.Join(outDir, "docs", "readme.md"))
+    if err != nil || string(got) != string(body) {
+        t.Errorf("extracted readme = %q, %v", got, err)
+    }
+}
+
+var sevenZipSignature = []byte("7z\xbc\xaf\x27\x1c\x00\x04")
+
+// build7z writes a 7z archive of a docs directory and the two archiveFiles,
+// stored with the copy coder in one folder. The file named corrupt gets a
+// CRC32 that does not match its contents.
+func build7z(t *testing.T, corrupt string) []byte {
+    t.Helper()
+    names := []string{"docs", "top.txt", "docs/readme.md"}
+    top, readme := []byte(archiveFiles["top.txt"]), []byte(archiveFiles["docs/readme.md"])
+    packed := append(append([]byte{}, top...), readme...)
+    crcs := []uint32{crc32.ChecksumIEEE(top), crc32.ChecksumIEEE(readme)}
+    for i, name := range names[1:] {
+        if name == corrupt {
+            crcs[i] ^= 1
+        }
+    }
+
+    // Every number below is under 0x80, so each fits 7z's one-byte form.
+    h := []byte{0x01, 0x04}                                           // header, main streams info
+    h = append(h, 0x06, 0x00, 0x01, 0x09, byte(len(packed)), 0x00)    // pack info: one stream
+    h = append(h, 0x07, 0x0b, 0x01, 0x00, 0x01, 0x01, 0x00)           // unpack info: one folder, copy coder
+    h = append(h, 0x0c, byte(len(packed)), 0x00)                      // its unpacked size
+    h = append(h, 0x08, 0x0d, 0x02, 0x09, byte(len(top)), 0x0a, 0x01) // substreams: two files, their CRCs
+    for _, crc := range crcs {
+        h = binary.LittleEndian.AppendUint32(h, crc)
+    }
+    h = append(h, 0x00, 0x00) // end of substreams and of streams info
+
+    h = append(h, 0x05, byte(len(names))) // files info
+    h = append(h, 0x0e, 0x01, 0x80)       // empty stream: the directory
+    var utf16 []byte
+    for _, name := range names {
+        for _, r := range name + "\x00" {
+            utf16 = append(utf16, byte(r), 0)
+        }
+    }
+    h = append(h, 0x11, byte(1+len(utf16)), 0x00)
+    h = append(h, utf16...)
+    h = append(h, 0x15, byte(2+4*len(names)), 0x01, 0x00) // attributes, all defined
+    for _, attr := range []uint32{0x10, 0x20, 0x20} {     // directory, archive, archive
+        h = binary.LittleEndian.AppendUint32(h, attr)
+    }
+    h = append(h, 0x00, 0x00) // end of files info and of header
+
+    next := binary.LittleEndian.AppendUint64(nil, uint64(len(packed)))
+    next = binary.LittleEndian.AppendUint64(next, uint64(len(h)))
+    next = binary.LittleEndian.AppendUint32(next, crc32.ChecksumIEEE(h))
+    out := append([]byte{}, sevenZipSignature...)
+    out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(next))
+    out = append(out, next...)
+    out = append(out, packed...)
+    return append(out, h...)
+}
+
+func TestSevenZipCRCMismatch(t *testing.T) {
+    data := build7z(t, "docs/readme.md")
+    a, err := streamline_core.NewArchive(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+        t.Fatalf("NewArchive failed: %v", err)
+    }
+    defer a.Close()
+
+    outDir := t.TempDir()
+    summary, err := a.Extract(context.Background(), outDir, streamline_core.ExtractOptions{ContinueOnError: true})
+    if err != nil {
+        t.Fatalf("Extract failed: %v", err)
+    }
+    if summary.Extracted != 2 || len(summary.Errors) != 1 {
+        t.Fatalf("summary = %+v; want docs/ and top.txt extracted, readme.md failed", summary)
+    }
+    if e := summary.Errors[0]; e.Name != "docs/readme.md" || !errors.Is(e, streamline_core.ErrVerifyFailed) {
+        t.Errorf("error = %v; want ErrVerifyFailed for docs/readme.md", e)
+    }
+    if _, err := os.Stat(filepath.Join(outDir, "docs", "readme.md")); !os.IsNotExist(err) {
+        t.Errorf("corrupt readme.md was left in place")
+    }
+}
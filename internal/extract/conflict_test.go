@@ -0,0 +1,77 @@
+package extract_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+func TestConflictPolicies(t *testing.T) {
+    data := buildMetadataZip(t) // bin/run.sh and data.txt, dated archivedTime
+    cases := []struct {
+        policy  streamline_core.ConflictPolicy
+        mtime   time.Time // of the existing data.txt
+        action  streamline_core.ConflictAction
+        content string // of data.txt afterwards
+        renamed string
+    }{
+        {streamline_core.ConflictOverwrite, archivedTime, streamline_core.ActionOverwritten, "data", ""},
+        {streamline_core.ConflictSkip, archivedTime, streamline_core.ActionSkipped, "old", ""},
+        {streamline_core.ConflictRename, archivedTime, streamline_core.ActionRenamed, "old", "data_1.txt"},
+        {streamline_core.ConflictNewer, archivedTime.Add(-time.Hour), streamline_core.ActionOverwritten, "data", ""},
+        {streamline_core.ConflictNewer, archivedTime.Add(time.Hour), streamline_core.ActionSkipped, "old", ""},
+        {streamline_core.ConflictFail, archivedTime, streamline_core.ActionFailed, "old", ""},
+    }
+
+    for _, tc := range cases {
+        t.Run(string(tc.policy)+"/"+string(tc.action), func(t *testing.T) {
+            outDir := t.TempDir()
+            existing := filepath.Join(outDir, "data.txt")
+            os.WriteFile(existing, []byte("old"), 0o644)
+            os.Chtimes(existing, tc.mtime, tc.mtime)
+
+            summary, err := extractWith(t, data, outDir, streamline_core.ExtractOptions{
+                Conflict:        tc.policy,
+                ContinueOnError: true,
+            })
+            if err != nil {
+                t.Fatalf("Extract failed: %v", err)
+            }
+            if len(summary.Conflicts) != 1 {
+                t.Fatalf("Conflicts = %+v; want one", summary.Conflicts)
+            }
+            c := summary.Conflicts[0]
+            if c.Name != "data.txt" || c.Policy != tc.policy || c.Action != tc.action {
+                t.Errorf("Conflict = %+v", c)
+            }
+            if got, _ := os.ReadFile(existing); string(got) != tc.content {
+                t.Errorf("data.txt = %q; want %q", got, tc.content)
+            }
+            if tc.renamed != "" {
+                want := filepath.Join(outDir, tc.renamed)
+                if got, _ := os.ReadFile(want); c.RenamedTo != want || string(got) != "data" {
+                    t.Errorf("renamed to %q holding %q; want %q", c.RenamedTo, got, want)
+                }
+            }
+
+            switch tc.action {
+            case streamline_core.ActionSkipped:
+                if summary.Skipped != 1 || summary.Extracted != 2 {
+                    t.Errorf("summary = %+v; want 1 skipped, 2 extracted", summary)
+                }
+            case streamline_core.ActionFailed:
+                if len(summary.Errors) != 1 || !errors.Is(summary.Errors[0], streamline_core.ErrTargetExists) {
+                    t.Errorf("Errors = %v; want ErrTargetExists", summary.Errors)
+                }
+            }
+        })
+    }
+
+    if _, err := streamline_core.ParseConflictPolicy("clobber"); err == nil {
+        t.Error("ParseConflictPolicy accepted an unknown policy")
+    }
+}
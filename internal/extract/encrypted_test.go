@@ -0,0 +1,215 @@
+package extract_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+const testPassword = "s3cret"
+
+var secretBody = bytes.Repeat([]byte("classified "), 500)
+
+// zipCryptoEncrypt encrypts data with the traditional PKWARE cipher,
+// prefixed by the 12-byte header whose last byte checks the password.
+func zipCryptoEncrypt(password string, crc uint32, data []byte) []byte {
+    k := [3]uint32{0x12345678, 0x23456789, 0x34567890}
+    update := func(b byte) {
+        k[0] = crc32.IEEETable[byte(k[0])^b] ^ k[0]>>8
+        k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+        k[2] = crc32.IEEETable[byte(k[2])^byte(k[1]>>24)] ^ k[2]>>8
+    }
+    for i := 0; i < len(password); i++ {
+        update(password[i])
+    }
+    plain := append(make([]byte, 11), byte(crc>>24))
+    plain = append(plain, data...)
+    out := make([]byte, len(plain))
+    for i, c := range plain {
+        t := k[2] | 2
+        out[i] = c ^ byte((t*(t^1))>>8)
+        update(c)
+    }
+    return out
+}
+
+// aesEncrypt produces WinZip AE-2 data for a stored entry.
+func aesEncrypt(t *testing.T, password string, keyLen int, data []byte) []byte {
+    salt := bytes.Repeat([]byte{7}, keyLen/2)
+    key, err := pbkdf2.Key(sha1.New, password, salt, 1000, 2*keyLen+2)
+    if err != nil {
+        t.Fatalf("pbkdf2: %v", err)
+    }
+    block, _ := aes.NewCipher(key[:keyLen])
+    ct := make([]byte, len(data))
+    var counter, stream [aes.BlockSize]byte
+    for i := range data {
+        if i%aes.BlockSize == 0 {
+            binary.LittleEndian.PutUint64(counter[:], uint64(i/aes.BlockSize+1))
+            block.Encrypt(stream[:], counter[:])
+        }
+        ct[i] = data[i] ^ stream[i%aes.BlockSize]
+    }
+    mac := hmac.New(sha1.New, key[keyLen:2*keyLen])
+    mac.Write(ct)
+    out := append(salt, key[2*keyLen:]...)
+    out = append(out, ct...)
+    return append(out, mac.Sum(nil)[:10]...)
+}
+
+func buildEncryptedZip(t *testing.T) []byte {
+    t.Helper()
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+
+    crc := crc32.ChecksumIEEE(secretBody)
+    w, _ := zw.CreateRaw(&zip.FileHeader{
+        Name:               "zipcrypto.txt",
+        Method:             zip.Store,
+        Flags:              0x1,
+        CRC32:              crc,
+        CompressedSize64:   uint64(len(secretBody) + 12),
+        UncompressedSize64: uint64(len(secretBody)),
+    })
+    w.Write(zipCryptoEncrypt(testPassword, crc, secretBody))
+
+    // AE-2, 256-bit key, stored.
+    extra := []byte{0x01, 0x99, 7, 0, 2, 0, 'A', 'E', 3, 0, 0}
+    enc := aesEncrypt(t, testPassword, 32, secretBody)
+    w, _ = zw.CreateRaw(&zip.FileHeader{
+        Name:               "aes.txt",
+        Method:             99,
+        Flags:              0x1,
+        Extra:              extra,
+        CompressedSize64:   uint64(len(enc)),
+        UncompressedSize64: uint64(len(secretBody)),
+    })
+    w.Write(enc)
+
+    w, _ = zw.Create("plain.txt")
+    w.Write([]byte("not secret"))
+    zw.Close()
+    return buf.Bytes()
+}
+
+func TestEncryptedZipEntries(t *testing.T) {
+    data := buildEncryptedZip(t)
+    open := func(password string) streamline_core.Archive {
+        a, err := streamline_core.NewArchiveWithOptions(bytes.NewReader(data), int64(len(data)),
+            streamline_core.ArchiveOptions{Password: password})
+        if err != nil {
+            t.Fatalf("NewArchive failed: %v", err)
+        }
+        return a
+    }
+
+    entries, _ := open("").List()
+    encrypted := map[string]bool{}
+    for _, e := range entries {
+        encrypted[e.Name] = e.Encrypted
+    }
+    if !encrypted["zipcrypto.txt"] || !encrypted["aes.txt"] || encrypted["plain.txt"] {
+        t.Errorf("Encrypted flags = %v", encrypted)
+    }
+
+    for _, name := range []string{"zipcrypto.txt", "aes.txt"} {
+        if _, err := open("").Open(name); !errors.Is(err, streamline_core.ErrPasswordRequired) {
+            t.Errorf("%s without password: err = %v; want ErrPasswordRequired", name, err)
+        }
+        if _, err := open("wrong").Open(name); !errors.Is(err, streamline_core.ErrBadPassword) {
+            t.Errorf("%s with wrong password: err = %v; want ErrBadPassword", name, err)
+        }
+        rc, err := open(testPassword).Open(name)
+        if err != nil {
+            t.Fatalf("%s: Open failed: %v", name, err)
+        }
+        got, err := io.ReadAll(rc)
+        rc.Close()
+        if err != nil || !bytes.Equal(got, secretBody) {
+            t.Errorf("%s: decrypted %d bytes, err %v", name, len(got), err)
+        }
+    }
+
+    summary, err := open(testPassword).Extract(context.Background(), t.TempDir(), streamline_core.ExtractOptions{})
+    if err != nil || summary.Extracted != 3 {
+        t.Errorf("Extract = %+v, %v; want 3 entries", summary, err)
+    }
+}
+
+func TestByteSplitZipJoins(t *testing.T) {
+    data := buildZip(t)
+    cut := len(data) / 3
+    joined, err := streamline_core.JoinSplitZip(
+        io.NewSectionReader(bytes.NewReader(data[:cut]), 0, int64(cut)),
+        io.NewSectionReader(bytes.NewReader(data[cut:2*cut]), 0, int64(cut)),
+        io.NewSectionReader(bytes.NewReader(data[2*cut:]), 0, int64(len(data)-2*cut)),
+    )
+    if err != nil {
+        t.Fatalf("JoinSplitZip failed: %v", err)
+    }
+    a, err := streamline_core.NewArchive(joined, joined.Size())
+    if err != nil {
+        t.Fatalf("NewArchive failed: %v", err)
+    }
+    rc, err := a.Open("docs/readme.md")
+    if err != nil {
+        t.Fatalf("Open failed: %v", err)
+    }
+    got, _ := io.ReadAll(rc)
+    if string(got) != archiveFiles["docs/readme.md"] {
+        t.Errorf("content = %q", got)
+    }
+}
+
+func TestSpannedZipFromInfoZip(t *testing.T) {
+    zipBin, err := exec.LookPath("zip")
+    if err != nil {
+        t.Skip("Info-ZIP not installed")
+    }
+    dir := t.TempDir()
+    big := bytes.Repeat([]byte("0123456789abcdef"), 16<<10) // 256 KiB, stored
+    os.WriteFile(filepath.Join(dir, "big.bin"), big, 0o644)
+    os.WriteFile(filepath.Join(dir, "small.txt"), []byte("small"), 0o644)
+    cmd := exec.Command(zipBin, "-q", "-0", "-s", "64k", "set.zip", "big.bin", "small.txt")
+    cmd.Dir = dir
+    if out, err := cmd.CombinedOutput(); err != nil {
+        t.Fatalf("zip -s failed: %v: %s", err, out)
+    }
+
+    // A lone disk is reported as part of a set rather than as garbage.
+    last := filepath.Join(dir, "set.zip")
+    st, _ := os.Stat(last)
+    f, _ := os.Open(last)
+    defer f.Close()
+    if _, err := streamline_core.NewArchive(f, st.Size()); !errors.Is(err, streamline_core.ErrSplitArchive) {
+        t.Errorf("single disk: err = %v; want ErrSplitArchive", err)
+    }
+
+    a, err := streamline_core.OpenArchive(filepath.Join(dir, "set.z01"))
+    if err != nil {
+        t.Fatalf("OpenArchive failed: %v", err)
+    }
+    defer a.Close()
+    outDir := t.TempDir()
+    if _, err := a.Extract(context.Background(), outDir, streamline_core.ExtractOptions{}); err != nil {
+        t.Fatalf("Extract failed: %v", err)
+    }
+    got, _ := os.ReadFile(filepath.Join(outDir, "big.bin"))
+    if !bytes.Equal(got, big) {
+        t.Errorf("big.bin differs after extraction (%d bytes)", len(got))
+    }
+}
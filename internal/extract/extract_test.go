@@ -3,6 +3,9 @@ package extract_test
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"errors"
+	"hash/crc32"
 	"os"
 	"path/filepath"
 	"testing"
@@ -91,3 +94,143 @@ func TestExtractFile(t *testing.T) {
         t.Errorf("Extracted content mismatch: got %q", string(data))
     }
 }
+
+func TestCheckpointResume(t *testing.T) {
+    outDir := t.TempDir()
+    if err := os.WriteFile(filepath.Join(outDir, "done.txt"), []byte("12345"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+
+    cp, err := streamline_core.LoadCheckpoint(outDir, "archive-1")
+    if err != nil {
+        t.Fatalf("LoadCheckpoint failed: %v", err)
+    }
+    if err := cp.MarkComplete("done.txt", 0xdeadbeef, 5); err != nil {
+        t.Fatalf("MarkComplete failed: %v", err)
+    }
+    cp.Close()
+
+    cp, err = streamline_core.LoadCheckpoint(outDir, "archive-1")
+    if err != nil {
+        t.Fatalf("reload failed: %v", err)
+    }
+    if !cp.IsComplete("done.txt", 0xdeadbeef, 5) {
+        t.Errorf("expected done.txt to be complete after reload")
+    }
+    if cp.IsComplete("done.txt", 0x1, 5) {
+        t.Errorf("CRC mismatch should not be complete")
+    }
+    if !cp.IsComplete("done.txt", 0xdeadbeef, -1) {
+        t.Errorf("an entry of unknown size should match the size recorded")
+    }
+    if cp.IsComplete("missing.txt", 0, 0) {
+        t.Errorf("unrecorded entry should not be complete")
+    }
+    cp.Close()
+
+    // A different archive must not reuse the manifest.
+    cp, err = streamline_core.LoadCheckpoint(outDir, "archive-2")
+    if err != nil {
+        t.Fatalf("LoadCheckpoint failed: %v", err)
+    }
+    defer cp.Close()
+    if cp.IsComplete("done.txt", 0xdeadbeef, 5) {
+        t.Errorf("checkpoint from another archive should be discarded")
+    }
+}
+
+func TestCheckpointUsesMappedPaths(t *testing.T) {
+    data := buildWrappedZip(t)
+    outDir := t.TempDir()
+    paths := streamline_core.PathMapping{StripComponents: 1}
+    // A stale file at the unmapped path must not count as extracted.
+    os.MkdirAll(filepath.Join(outDir, "project-1.0"), 0o755)
+    os.WriteFile(filepath.Join(outDir, "project-1.0", "README"), []byte("project-1.0/README"), 0o644)
+
+    extract := func(paths streamline_core.PathMapping) *streamline_core.ExtractSummary {
+        t.Helper()
+        cp, err := streamline_core.LoadCheckpointWithPaths(outDir, "archive-1", paths)
+        if err != nil {
+            t.Fatalf("LoadCheckpointWithPaths failed: %v", err)
+        }
+        defer cp.Close()
+        arc, err := streamline_core.NewArchive(bytes.NewReader(data), int64(len(data)))
+        if err != nil {
+            t.Fatalf("NewArchive failed: %v", err)
+        }
+        defer arc.Close()
+        summary, _ := arc.Extract(context.Background(), outDir, streamline_core.ExtractOptions{
+            ContinueOnError: true,
+            Paths:           paths,
+            Checkpoint:      cp,
+        })
+        return summary
+    }
+
+    first := extract(paths)
+    if first.Resumed != 0 || first.Extracted == 0 {
+        t.Fatalf("first run: %+v", first)
+    }
+    if _, err := os.Stat(filepath.Join(outDir, "README")); err != nil {
+        t.Fatalf("README not extracted to its mapped path: %v", err)
+    }
+    second := extract(paths)
+    if second.Resumed != first.Extracted || second.Extracted != 0 {
+        t.Errorf("second run resumed %d and extracted %d; want %d and 0", second.Resumed, second.Extracted, first.Extracted)
+    }
+    // Other mapping options put files elsewhere, so the manifest is dropped.
+    third := extract(streamline_core.PathMapping{StripComponents: 1, Flatten: true})
+    if third.Resumed != 0 {
+        t.Errorf("resumed %d entries under different path mapping", third.Resumed)
+    }
+}
+
+func TestCheckpointResumesEntriesOfUnknownSize(t *testing.T) {
+    body := []byte("size only known once read")
+    data := append([]byte{}, rar5Signature...)
+    data = append(data, rar5Header(1, 0, 0)...)
+    data = append(data, rar5File("stream.txt", body, -1, crc32.ChecksumIEEE(body), false, 0)...)
+    data = append(data, rar5Header(5, 0, 0)...)
+    outDir := t.TempDir()
+
+    extract := func() *streamline_core.ExtractSummary {
+        t.Helper()
+        cp, err := streamline_core.LoadCheckpoint(outDir, "archive-1")
+        if err != nil {
+            t.Fatalf("LoadCheckpoint failed: %v", err)
+        }
+        defer cp.Close()
+        summary, err := extractWith(t, data, outDir, streamline_core.ExtractOptions{Checkpoint: cp})
+        if err != nil {
+            t.Fatalf("Extract failed: %v", err)
+        }
+        return summary
+    }
+
+    if first := extract(); first.Extracted != 1 {
+        t.Fatalf("first run: %+v", first)
+    }
+    if second := extract(); second.Resumed != 1 || second.Extracted != 0 {
+        t.Errorf("second run: %+v; want the entry resumed", second)
+    }
+}
+
+func TestExtractFileContextCancelled(t *testing.T) {
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+    w, _ := zw.Create("big.bin")
+    w.Write(bytes.Repeat([]byte("x"), 4<<20))
+    zw.Close()
+
+    zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+    if err != nil {
+        t.Fatalf("Failed to read zip: %v", err)
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+    err = streamline_core.ExtractFileContext(ctx, zr.File[0], filepath.Join(t.TempDir(), "big.bin"))
+    if !errors.Is(err, context.Canceled) {
+        t.Errorf("ExtractFileContext error = %v; want context.Canceled", err)
+    }
+}
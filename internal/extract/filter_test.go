@@ -0,0 +1,82 @@
+package extract_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+func TestMatchPath(t *testing.T) {
+    tests := []struct {
+        pattern, name string
+        expected      bool
+    }{
+        {"*.md", "docs/deep/readme.md", true},
+        {"docs/**/*.md", "docs/a/b/readme.md", true},
+        {"docs/**/*.md", "docs/readme.md", true},
+        {"docs/**/*.md", "src/docs/readme.md", false},
+        {"node_modules", "web/node_modules/pkg/index.js", true},
+        {"node_modules/", "node_modules/", true},
+        {"/build", "build/out.bin", true},
+        {"/build", "src/build/out.bin", false},
+        {"src/*.go", "src/sub/main.go", false},
+        {"*.exe", "setup.exe.txt", false},
+    }
+    for _, tt := range tests {
+        if got := streamline_core.MatchPath(tt.pattern, tt.name); got != tt.expected {
+            t.Errorf("MatchPath(%q, %q) = %v; want %v", tt.pattern, tt.name, got, tt.expected)
+        }
+    }
+}
+
+func TestFilterFile(t *testing.T) {
+    rules := `
+# keep docs and sources, minus vendored code
++docs/**
++*.go
+vendor
+!vendor/keep.go
+@min-size 1KB
+@max-size 1MiB
+@after 2024-01-01
+@before 2025-01-01T00:00:00Z
+`
+    f, err := streamline_core.ParseFilter(strings.NewReader(rules))
+    if err != nil {
+        t.Fatalf("ParseFilter failed: %v", err)
+    }
+    if f.MinSize != 1000 || f.MaxSize != 1<<20 {
+        t.Errorf("sizes = %d, %d", f.MinSize, f.MaxSize)
+    }
+
+    when := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+    entry := func(name string, size int64, mod time.Time) streamline_core.Entry {
+        return streamline_core.Entry{Name: name, Size: size, Modified: mod}
+    }
+    tests := []struct {
+        e        streamline_core.Entry
+        expected bool
+    }{
+        {entry("docs/guide/intro.md", 4096, when), true},
+        {entry("cmd/main.go", 4096, when), true},
+        {entry("README.txt", 4096, when), false},                   // not included
+        {entry("vendor/lib/x.go", 4096, when), false},              // excluded
+        {entry("vendor/keep.go", 4096, when), true},                // re-included
+        {entry("cmd/tiny.go", 10, when), false},                    // too small
+        {entry("cmd/huge.go", 2 << 20, when), false},               // too large
+        {entry("cmd/old.go", 4096, when.AddDate(-1, 0, 0)), false}, // too old
+        {entry("cmd/new.go", 4096, when.AddDate(1, 0, 0)), false},  // too new
+        {streamline_core.Entry{Name: "docs/", IsDir: true}, true},
+    }
+    for _, tt := range tests {
+        if got := f.Match(tt.e); got != tt.expected {
+            t.Errorf("Match(%s) = %v; want %v", tt.e.Name, got, tt.expected)
+        }
+    }
+
+    if _, err := streamline_core.ParseFilter(strings.NewReader("@max-size lots")); err == nil {
+        t.Error("ParseFilter accepted an invalid size")
+    }
+}
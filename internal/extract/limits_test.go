@@ -0,0 +1,125 @@
+package extract_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+// buildBombZip holds one highly compressible entry plus a few small ones.
+func buildBombZip(t *testing.T) []byte {
+    t.Helper()
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+    w, _ := zw.Create("zeros.bin")
+    w.Write(make([]byte, 4<<20))
+    for _, name := range []string{"a.txt", "b.txt", "deep/er/than/allowed.txt"} {
+        w, _ := zw.Create(name)
+        w.Write([]byte(strings.Repeat(name, 10)))
+    }
+    zw.Close()
+    return buf.Bytes()
+}
+
+func TestExtractLimits(t *testing.T) {
+    data := buildBombZip(t)
+    cases := []struct {
+        name   string
+        limits streamline_core.Limits
+        kind   streamline_core.LimitKind
+        entry  string
+        aborts bool
+    }{
+        {"ratio", streamline_core.Limits{MaxRatio: 100}, streamline_core.LimitRatio, "zeros.bin", false},
+        {"entry bytes", streamline_core.Limits{MaxEntryBytes: 1 << 20}, streamline_core.LimitEntryBytes, "zeros.bin", false},
+        {"total bytes", streamline_core.Limits{MaxTotalBytes: 2 << 20}, streamline_core.LimitTotalBytes, "zeros.bin", true},
+        {"entries", streamline_core.Limits{MaxEntries: 2}, streamline_core.LimitEntries, "b.txt", true},
+        {"depth", streamline_core.Limits{MaxDepth: 2}, streamline_core.LimitDepth, "deep/er/than/allowed.txt", false},
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            a, err := streamline_core.NewArchive(bytes.NewReader(data), int64(len(data)))
+            if err != nil {
+                t.Fatalf("NewArchive failed: %v", err)
+            }
+            outDir := t.TempDir()
+            summary, err := a.Extract(context.Background(), outDir, streamline_core.ExtractOptions{
+                ContinueOnError: true,
+                Limits:          tc.limits,
+            })
+            if tc.aborts != (err != nil) {
+                t.Errorf("Extract error = %v; aborts = %v", err, tc.aborts)
+            }
+
+            var found *streamline_core.EntryError
+            for _, e := range summary.Errors {
+                if e.Name == tc.entry {
+                    found = e
+                }
+            }
+            if found == nil {
+                t.Fatalf("no error recorded for %s: %+v", tc.entry, summary.Errors)
+            }
+            var lerr *streamline_core.LimitError
+            if !errors.As(found, &lerr) || lerr.Kind != tc.kind {
+                t.Errorf("error = %v; want %s", found, tc.kind)
+            }
+            if !errors.Is(found, streamline_core.ErrLimitExceeded) {
+                t.Errorf("error does not match ErrLimitExceeded")
+            }
+            if code := streamline_core.ErrorCode(found); code != string(tc.kind) {
+                t.Errorf("ErrorCode = %q; want %q", code, tc.kind)
+            }
+            if _, err := os.Stat(filepath.Join(outDir, filepath.FromSlash(tc.entry))); !os.IsNotExist(err) {
+                t.Errorf("partial output of %s left behind", tc.entry)
+            }
+            if !tc.aborts && summary.Extracted != 3 {
+                t.Errorf("Extracted = %d; want the 3 other entries", summary.Extracted)
+            }
+        })
+    }
+}
+
+func TestExtractLimitsTarGzRatio(t *testing.T) {
+    // Tar entries have no compressed size of their own; the ratio is
+    // judged from the gzip stream consumed so far.
+    buf := new(bytes.Buffer)
+    gz, _ := gzip.NewWriterLevel(buf, gzip.BestCompression)
+    tw := tar.NewWriter(gz)
+    for _, name := range []string{"zeros-1.bin", "zeros-2.bin"} {
+        tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 8 << 20, Typeflag: tar.TypeReg})
+        tw.Write(make([]byte, 8<<20))
+    }
+    tw.Close()
+    gz.Close()
+    data := buf.Bytes()
+
+    // Zeros deflate at about 1030:1, just past what the defaults allow.
+    for _, limits := range []streamline_core.Limits{{MaxRatio: 100}, streamline_core.DefaultLimits} {
+        a, err := streamline_core.NewArchive(bytes.NewReader(data), int64(len(data)))
+        if err != nil {
+            t.Fatalf("NewArchive failed: %v", err)
+        }
+        summary, err := a.Extract(context.Background(), t.TempDir(), streamline_core.ExtractOptions{
+            ContinueOnError: true,
+            Limits:          limits,
+        })
+        var lerr *streamline_core.LimitError
+        if !errors.As(err, &lerr) || lerr.Kind != streamline_core.LimitRatio {
+            t.Fatalf("MaxRatio %g: Extract error = %v; want a %s abort", limits.MaxRatio, err, streamline_core.LimitRatio)
+        }
+        if summary.Extracted != 0 {
+            t.Errorf("MaxRatio %g: extracted %d entries of a stream over the ratio", limits.MaxRatio, summary.Extracted)
+        }
+    }
+}
@@ -0,0 +1,140 @@
+package extract_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+var archivedTime = time.Date(2021, 6, 15, 12, 30, 0, 0, time.UTC)
+
+func addZipEntry(t *testing.T, zw *zip.Writer, name string, mode os.FileMode, body string) {
+    t.Helper()
+    h := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: archivedTime}
+    h.SetMode(mode)
+    w, err := zw.CreateHeader(h)
+    if err != nil {
+        t.Fatalf("CreateHeader(%s): %v", name, err)
+    }
+    w.Write([]byte(body))
+}
+
+// buildMetadataZip holds an executable, a read-only directory and links
+// given as name -> target pairs.
+func buildMetadataZip(t *testing.T, links ...string) []byte {
+    t.Helper()
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+    addZipEntry(t, zw, "bin/", os.ModeDir|0o555, "")
+    addZipEntry(t, zw, "bin/run.sh", 0o755, "#!/bin/sh\n")
+    addZipEntry(t, zw, "data.txt", 0o600, "data")
+    for i := 0; i+1 < len(links); i += 2 {
+        addZipEntry(t, zw, links[i], os.ModeSymlink|0o777, links[i+1])
+    }
+    zw.Close()
+    return buf.Bytes()
+}
+
+func extractWith(t *testing.T, data []byte, outDir string, opts streamline_core.ExtractOptions) (*streamline_core.ExtractSummary, error) {
+    t.Helper()
+    a, err := streamline_core.NewArchive(bytes.NewReader(data), int64(len(data)))
+    if err != nil {
+        t.Fatalf("NewArchive failed: %v", err)
+    }
+    return a.Extract(context.Background(), outDir, opts)
+}
+
+func TestPreserveModesAndTimes(t *testing.T) {
+    outDir := t.TempDir()
+    data := buildMetadataZip(t)
+    if _, err := extractWith(t, data, outDir, streamline_core.ExtractOptions{PreserveModes: true, PreserveTimes: true}); err != nil {
+        t.Fatalf("Extract failed: %v", err)
+    }
+    defer os.Chmod(filepath.Join(outDir, "bin"), 0o755)
+
+    for name, want := range map[string]os.FileMode{"bin": 0o555, "bin/run.sh": 0o755, "data.txt": 0o600} {
+        st, err := os.Stat(filepath.Join(outDir, name))
+        if err != nil {
+            t.Fatalf("stat %s: %v", name, err)
+        }
+        if st.Mode().Perm() != want {
+            t.Errorf("%s mode = %v; want %v", name, st.Mode().Perm(), want)
+        }
+        if !st.ModTime().Equal(archivedTime) {
+            t.Errorf("%s mtime = %v; want %v", name, st.ModTime(), archivedTime)
+        }
+    }
+
+    // Without the options, files get default permissions and the current time.
+    plain := t.TempDir()
+    if _, err := extractWith(t, data, plain, streamline_core.ExtractOptions{}); err != nil {
+        t.Fatalf("Extract failed: %v", err)
+    }
+    st, _ := os.Stat(filepath.Join(plain, "data.txt"))
+    if st.ModTime().Equal(archivedTime) {
+        t.Error("mtime preserved without PreserveTimes")
+    }
+}
+
+func TestPreserveSymlinks(t *testing.T) {
+    opts := streamline_core.ExtractOptions{PreserveSymlinks: true, ContinueOnError: true}
+
+    outDir := t.TempDir()
+    data := buildMetadataZip(t, "bin/run", "run.sh", "top", "bin/../data.txt")
+    if _, err := extractWith(t, data, outDir, opts); err != nil {
+        t.Fatalf("Extract failed: %v", err)
+    }
+    for name, want := range map[string]string{"bin/run": "run.sh", "top": "bin/../data.txt"} {
+        got, err := os.Readlink(filepath.Join(outDir, name))
+        if err != nil || got != want {
+            t.Errorf("Readlink(%s) = %q, %v; want %q", name, got, err, want)
+        }
+    }
+
+    unsafe := map[string][]string{
+        "parent":   {"evil", "../outside"},
+        "absolute": {"evil", "/etc/passwd"},
+        // Lexically "b" stays inside, but "a" is the output dir itself.
+        "chain": {"a", ".", "b", "a/.."},
+    }
+    for name, links := range unsafe {
+        t.Run(name, func(t *testing.T) {
+            outDir := t.TempDir()
+            summary, _ := extractWith(t, buildMetadataZip(t, links...), outDir, opts)
+            var failed bool
+            for _, e := range summary.Errors {
+                failed = failed || errors.Is(e, streamline_core.ErrUnsafeSymlink)
+            }
+            if !failed {
+                t.Errorf("no ErrUnsafeSymlink in %v", summary.Errors)
+            }
+            if _, err := os.Lstat(filepath.Join(outDir, links[len(links)-2])); err == nil {
+                t.Errorf("unsafe link %s was created", links[len(links)-2])
+            }
+        })
+    }
+
+    // A link written earlier must not let a later entry escape through it.
+    outside := t.TempDir()
+    outDir = t.TempDir()
+    os.Symlink(outside, filepath.Join(outDir, "bin"))
+    extractWith(t, buildMetadataZip(t), outDir, opts)
+    if _, err := os.Stat(filepath.Join(outside, "run.sh")); err == nil {
+        t.Error("entry was written through a symlink leaving the output directory")
+    }
+
+    // Without PreserveSymlinks the link is a regular file holding its target.
+    outDir = t.TempDir()
+    extractWith(t, buildMetadataZip(t, "evil", "../outside"), outDir, streamline_core.ExtractOptions{})
+    got, err := os.ReadFile(filepath.Join(outDir, "evil"))
+    if err != nil || string(got) != "../outside" {
+        t.Errorf("link written as file = %q, %v", got, err)
+    }
+}
@@ -0,0 +1,120 @@
+package extract_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+func TestRepack(t *testing.T) {
+    src := buildZip(t)
+    for _, format := range streamline_core.PackFormats {
+        t.Run(string(format), func(t *testing.T) {
+            a, err := streamline_core.NewArchive(bytes.NewReader(src), int64(len(src)))
+            if err != nil {
+                t.Fatalf("NewArchive failed: %v", err)
+            }
+            out := new(bytes.Buffer)
+            aw, err := streamline_core.NewArchiveWriter(out, format, streamline_core.BestSpeed)
+            if err != nil {
+                t.Fatalf("NewArchiveWriter failed: %v", err)
+            }
+            summary, err := streamline_core.Repack(context.Background(), a, aw, streamline_core.ExtractOptions{
+                Select: func(e streamline_core.Entry) bool { return e.Name == "docs/readme.md" },
+                Paths:  streamline_core.PathMapping{Rewrites: []streamline_core.PathRewrite{{From: "docs", To: "manual"}}},
+            })
+            if err != nil {
+                t.Fatalf("Repack failed: %v", err)
+            }
+            if err := aw.Close(); err != nil {
+                t.Fatalf("Close failed: %v", err)
+            }
+            if summary.Extracted != 1 || summary.Skipped != 1 {
+                t.Errorf("summary = %+v; want 1 copied, 1 skipped", summary)
+            }
+
+            packed, err := streamline_core.NewArchive(bytes.NewReader(out.Bytes()), int64(out.Len()))
+            if err != nil {
+                t.Fatalf("reading the new archive failed: %v", err)
+            }
+            if packed.Format() != format {
+                t.Errorf("new archive is %s; want %s", packed.Format(), format)
+            }
+            rc, err := packed.Open("manual/readme.md")
+            if err != nil {
+                t.Fatalf("Open failed: %v", err)
+            }
+            defer rc.Close()
+            if got, _ := io.ReadAll(rc); string(got) != archiveFiles["docs/readme.md"] {
+                t.Errorf("manual/readme.md = %q", got)
+            }
+        })
+    }
+}
+
+func TestArchiveWriterRejectsEscapingNames(t *testing.T) {
+    aw, _ := streamline_core.NewArchiveWriter(io.Discard, streamline_core.FormatZip, streamline_core.DefaultCompression)
+    for _, name := range []string{"../escape.txt", "/abs.txt", "a/../../b"} {
+        if err := aw.Add(name, streamline_core.Entry{Size: 1}, bytes.NewReader([]byte("x"))); err == nil {
+            t.Errorf("Add(%q) succeeded", name)
+        }
+    }
+}
+
+func TestPackDirectory(t *testing.T) {
+    dir := t.TempDir()
+    for name, body := range map[string]string{"src/main.go": "package main", "src/main.o": "obj", "README": "hi"} {
+        path := filepath.Join(dir, filepath.FromSlash(name))
+        if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := os.Symlink("src/main.go", filepath.Join(dir, "link")); err != nil {
+        t.Skipf("symlinks unavailable: %v", err)
+    }
+    filter, err := streamline_core.ParseFilter(strings.NewReader("*.o\n"))
+    if err != nil {
+        t.Fatalf("ParseFilter failed: %v", err)
+    }
+
+    out := new(bytes.Buffer)
+    aw, _ := streamline_core.NewArchiveWriter(out, streamline_core.FormatTarGz, streamline_core.DefaultCompression)
+    summary, err := streamline_core.Pack(context.Background(), streamline_core.DirSource{Dir: dir}, aw, streamline_core.ExtractOptions{Select: filter.Match})
+    if err != nil {
+        t.Fatalf("Pack failed: %v", err)
+    }
+    if err := aw.Close(); err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+    if summary.Extracted != 4 || summary.Skipped != 1 {
+        t.Errorf("summary = %+v; want 4 packed, 1 skipped", summary)
+    }
+
+    packed, err := streamline_core.NewArchive(bytes.NewReader(out.Bytes()), int64(out.Len()))
+    if err != nil {
+        t.Fatalf("reading the new archive failed: %v", err)
+    }
+    entries, err := packed.List()
+    if err != nil {
+        t.Fatalf("List failed: %v", err)
+    }
+    var names []string
+    for _, e := range entries {
+        names = append(names, e.Name)
+        if e.Name == "link" && (!e.IsSymlink() || e.Linkname != "src/main.go") {
+            t.Errorf("link = %+v; want a symlink to src/main.go", e)
+        }
+    }
+    if got, want := strings.Join(names, ","), "README,link,src/,src/main.go"; got != want {
+        t.Errorf("entries = %s; want %s", got, want)
+    }
+}
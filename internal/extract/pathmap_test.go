@@ -0,0 +1,84 @@
+package extract_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+func buildWrappedZip(t *testing.T) []byte {
+    t.Helper()
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+    for _, name := range []string{"project-1.0/", "project-1.0/README", "project-1.0/src/main.go",
+        "project-1.0/src/util/main.go", "project-1.0/docs/main.go", "project-1.0/src/../../escape.txt"} {
+        w, _ := zw.Create(name)
+        if !strings.HasSuffix(name, "/") {
+            w.Write([]byte(name))
+        }
+    }
+    zw.Close()
+    return buf.Bytes()
+}
+
+// tree lists the files under dir as slash-separated paths.
+func tree(t *testing.T, dir string) []string {
+    t.Helper()
+    var files []string
+    filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+        if err == nil && !d.IsDir() {
+            rel, _ := filepath.Rel(dir, p)
+            files = append(files, filepath.ToSlash(rel))
+        }
+        return nil
+    })
+    sort.Strings(files)
+    return files
+}
+
+func TestPathMapping(t *testing.T) {
+    rewrite, err := streamline_core.ParsePathRewrite("src/ -> lib/")
+    if err != nil {
+        t.Fatalf("ParsePathRewrite failed: %v", err)
+    }
+    cases := []struct {
+        name  string
+        paths streamline_core.PathMapping
+        want  string
+    }{
+        {"strip", streamline_core.PathMapping{StripComponents: 1},
+            "README docs/main.go src/main.go src/util/main.go"},
+        {"rewrite", streamline_core.PathMapping{StripComponents: 1, Rewrites: []streamline_core.PathRewrite{rewrite}},
+            "README docs/main.go lib/main.go lib/util/main.go"},
+        {"flatten", streamline_core.PathMapping{Flatten: true},
+            "README escape.txt main.go main_1.go main_2.go"},
+    }
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            outDir := t.TempDir()
+            summary, _ := extractWith(t, buildWrappedZip(t), outDir, streamline_core.ExtractOptions{
+                Paths:           tc.paths,
+                ContinueOnError: true,
+                Workers:         4,
+            })
+            if got := strings.Join(tree(t, outDir), " "); got != tc.want {
+                t.Errorf("tree = %s; want %s", got, tc.want)
+            }
+            // The traversal check sees mapped paths: flattening makes
+            // escape.txt harmless, stripping leaves it escaping.
+            if tc.name != "flatten" && len(summary.Errors) != 1 {
+                t.Errorf("Errors = %v; want the traversal entry rejected", summary.Errors)
+            }
+        })
+    }
+
+    if _, err := streamline_core.ParsePathRewrite("=lib"); err == nil {
+        t.Error("ParsePathRewrite accepted an empty prefix")
+    }
+}
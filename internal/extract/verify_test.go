@@ -0,0 +1,81 @@
+package extract_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	streamline_core "Streamline/cmd/streamline_core"
+)
+
+func TestCorruptEntryLeavesTargetUntouched(t *testing.T) {
+    body := []byte("payload that will not match its crc")
+    buf := new(bytes.Buffer)
+    zw := zip.NewWriter(buf)
+    w, _ := zw.CreateRaw(&zip.FileHeader{
+        Name:               "bad.txt",
+        Method:             zip.Store,
+        CRC32:              crc32.ChecksumIEEE(body) ^ 1,
+        CompressedSize64:   uint64(len(body)),
+        UncompressedSize64: uint64(len(body)),
+    })
+    w.Write(body)
+    zw.Close()
+    zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+    if err != nil {
+        t.Fatalf("Failed to read zip: %v", err)
+    }
+
+    dir := t.TempDir()
+    target := filepath.Join(dir, "bad.txt")
+    os.WriteFile(target, []byte("previous"), 0o644)
+    if err := streamline_core.ExtractFile(zr.File[0], target); err == nil {
+        t.Fatal("ExtractFile accepted an entry with a bad CRC")
+    }
+    if got, _ := os.ReadFile(target); string(got) != "previous" {
+        t.Errorf("target = %q; want it untouched", got)
+    }
+    if files, _ := os.ReadDir(dir); len(files) != 1 {
+        t.Errorf("temp files left behind: %v", files)
+    }
+}
+
+func TestVerifyTree(t *testing.T) {
+    for name, data := range map[string][]byte{"zip": buildZip(t), "tar": buildTar(t), "7z": build7z(t, "")} {
+        t.Run(name, func(t *testing.T) {
+            a, err := streamline_core.NewArchive(bytes.NewReader(data), int64(len(data)))
+            if err != nil {
+                t.Fatalf("NewArchive failed: %v", err)
+            }
+            outDir := t.TempDir()
+            if _, err := a.Extract(context.Background(), outDir, streamline_core.ExtractOptions{}); err != nil {
+                t.Fatalf("Extract failed: %v", err)
+            }
+
+            summary, err := streamline_core.Verify(context.Background(), a, outDir, streamline_core.ExtractOptions{})
+            if err != nil || len(summary.Errors) != 0 || summary.Verified != summary.Total {
+                t.Fatalf("Verify of a clean tree = %+v, %v", summary, err)
+            }
+
+            os.WriteFile(filepath.Join(outDir, "top.txt"), []byte("top levex"), 0o644)
+            os.Remove(filepath.Join(outDir, "docs", "readme.md"))
+            summary, err = streamline_core.Verify(context.Background(), a, outDir, streamline_core.ExtractOptions{})
+            if err != nil {
+                t.Fatalf("Verify failed: %v", err)
+            }
+            if len(summary.Errors) != 2 {
+                t.Fatalf("Errors = %v; want the changed and the missing file", summary.Errors)
+            }
+            for _, e := range summary.Errors {
+                if !errors.Is(e, streamline_core.ErrVerifyFailed) {
+                    t.Errorf("%v is not ErrVerifyFailed", e)
+                }
+            }
+        })
+    }
+}
@@ -16,3 +16,21 @@ func PrintProgress(current, total int) {
         fmt.Println()
     }
 }
+
+// PrintTransfer shows bytes moved so far, as a bar when total is known
+// (total < 0 means unknown).
+func PrintTransfer(sent, total int64) {
+    if total <= 0 {
+        fmt.Printf("\r%.1f MB", float64(sent)/(1<<20))
+        return
+    }
+    percent := float64(sent) / float64(total)
+    barLength := 40
+    filled := int(percent * float64(barLength))
+
+    bar := strings.Repeat("█", filled) + strings.Repeat("-", barLength-filled)
+    fmt.Printf("\r[%s] %.2f%% (%.1f / %.1f MB)", bar, percent*100, float64(sent)/(1<<20), float64(total)/(1<<20))
+    if sent == total {
+        fmt.Println()
+    }
+}